@@ -4,14 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/kznrluk/aski/config"
 	"github.com/kznrluk/aski/conv"
 	"github.com/kznrluk/go-anthropic"
 	"io"
+	"sync"
 )
 
 type (
 	ap struct {
-		ac *anthropic.Client
+		ac      *anthropic.Client
+		catalog config.Catalog
 	}
 )
 
@@ -35,18 +38,7 @@ func (a ap) RetrieveStream(conv conv.Conversation) (string, error) {
 }
 
 func (a ap) rest(ctx context.Context, conv conv.Conversation) (string, error) {
-	messages := conv.ToAnthropicMessage()
-	model := conv.GetProfile().Model
-	rest, err := a.ac.CreateMessage(
-		ctx,
-		anthropic.MessageRequest{
-			MaxTokens: 4096,
-			Model:     model,
-			System:    conv.GetSystem(),
-			Messages:  messages,
-		},
-	)
-
+	rest, err := a.ac.CreateMessage(ctx, a.messageRequest(conv))
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			return "", ErrCancelled
@@ -61,18 +53,7 @@ func (a ap) rest(ctx context.Context, conv conv.Conversation) (string, error) {
 }
 
 func (a ap) stream(ctx context.Context, conv conv.Conversation) (string, error) {
-	messages := conv.ToAnthropicMessage()
-	model := conv.GetProfile().Model
-	stream, err := a.ac.CreateMessageStream(
-		ctx,
-		anthropic.MessageRequest{
-			MaxTokens: 4096,
-			Model:     model,
-			System:    conv.GetSystem(),
-			Messages:  messages,
-		},
-	)
-
+	stream, err := a.ac.CreateMessageStream(ctx, a.messageRequest(conv))
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			return "", ErrCancelled
@@ -100,6 +81,33 @@ func (a ap) stream(ctx context.Context, conv conv.Conversation) (string, error)
 	return data, nil
 }
 
-func NewAnthropic(key string) Chat {
-	return ap{ac: anthropic.NewClient(key)}
+// messageRequest returns the request for the conversation. max_tokens is required by the API,
+// so the output limit of the model is sent unless the profile sets one.
+func (a ap) messageRequest(conv conv.Conversation) anthropic.MessageRequest {
+	profile := conv.GetProfile()
+	system, messages := conv.ToAnthropicMessage()
+
+	maxTokens := profile.CustomParameters.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = a.catalog.MaxOutputTokens(profile.Model)
+	}
+	if profile.CustomParameters.Temperature != 0 {
+		warnTemperature.Do(func() {
+			fmt.Printf("WARN: temperature is not supported for Claude models yet and is ignored.\n")
+		})
+	}
+
+	return anthropic.MessageRequest{
+		MaxTokens: maxTokens,
+		Model:     profile.Model,
+		System:    system,
+		Messages:  messages,
+	}
+}
+
+// warnTemperature prints the warning once per process, as it would otherwise repeat on every message.
+var warnTemperature sync.Once
+
+func NewAnthropic(key string, catalog config.Catalog) Chat {
+	return ap{ac: anthropic.NewClient(key), catalog: catalog}
 }
@@ -7,7 +7,6 @@ import (
 	"github.com/kznrluk/aski/conv"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 )
 
@@ -24,8 +23,9 @@ var (
 )
 
 func ProvideChat(model string, cfg config.Config) Chat {
-	if strings.HasPrefix(model, "claude") {
-		return NewAnthropic(cfg.AnthropicAPIKey)
+	catalog := cfg.Catalog()
+	if catalog.Provider(model) == config.ProviderAnthropic {
+		return NewAnthropic(cfg.AnthropicAPIKey, catalog)
 	}
 
 	return NewOpenAI(cfg.OpenAIAPIKey)
@@ -38,12 +38,6 @@ func (o oai) rest(ctx context.Context, conv conv.Conversation) (string, error) {
 	profile := conv.GetProfile()
 	customParams := profile.CustomParameters
 	messages := conv.ToOpenAIMessage()
-	system := openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
-		Content: conv.GetSystem(),
-	}
-
-	messages = append([]openai.ChatCompletionMessage{system}, messages...)
 
 	resp, err := o.oc.CreateChatCompletion(
 		ctx,
@@ -75,12 +69,6 @@ func (o oai) stream(ctx context.Context, conv conv.Conversation) (string, error)
 	profile := conv.GetProfile()
 	customParams := profile.CustomParameters
 	messages := conv.ToOpenAIMessage()
-	system := openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
-		Content: conv.GetSystem(),
-	}
-
-	messages = append([]openai.ChatCompletionMessage{system}, messages...)
 
 	stream, err := o.oc.CreateChatCompletionStream(
 		ctx,
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/kznrluk/aski/chat"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/session"
+	"sort"
+	"strings"
+)
+
+func cherryPick(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return nil, false, fmt.Errorf("no SHA1 provided")
+	}
+
+	copied, err := cv.CherryPick(strings.TrimSpace(args[0]))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to cherry-pick: %v", err)
+	}
+
+	for _, msg := range copied {
+		printMessage(msg)
+	}
+
+	return cv, false, nil
+}
+
+// rebase handles `:rebase branch [onto] sha [--reask]`.
+func rebase(cv conv.Conversation, cli chat.Chat, args []string) (conv.Conversation, bool, error) {
+	reask := false
+	var positional []string
+	for _, arg := range args {
+		switch strings.TrimSpace(arg) {
+		case "":
+			continue
+		case "--reask":
+			reask = true
+		case "onto":
+			continue
+		default:
+			positional = append(positional, strings.TrimSpace(arg))
+		}
+	}
+
+	if len(positional) != 2 {
+		return nil, false, fmt.Errorf("usage: :rebase <branch sha1> onto <sha1> [--reask]")
+	}
+	branch, onto := positional[0], positional[1]
+
+	if !reask {
+		copied, err := cv.Rebase(branch, onto)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to rebase: %v", err)
+		}
+
+		for _, msg := range copied {
+			printMessage(msg)
+		}
+		return cv, false, nil
+	}
+
+	d, err := cv.Diverge(branch, onto)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to rebase: %v", err)
+	}
+
+	if len(d.A) == 0 {
+		return nil, false, fmt.Errorf("nothing to rebase: %s is already on the path of %s", branch, onto)
+	}
+
+	if _, err := cv.ChangeHead(onto); err != nil {
+		return nil, false, err
+	}
+
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	for _, m := range d.A {
+		if m.Role == conv.ChatRoleSystem {
+			printMessage(cv.Copy(m))
+			continue
+		}
+
+		if m.Role != conv.ChatRoleUser {
+			continue
+		}
+
+		msg := cv.Copy(m)
+		printMessage(msg)
+
+		fmt.Print(yellow(fmt.Sprintf("\n%s -> [%.*s]\n", conv.ChatRoleAssistant, 6, msg.Sha1)))
+		data, err := cli.Retrieve(cv, session.RestMode())
+		if err != nil {
+			if errors.Is(err, chat.ErrCancelled) {
+				return cv, false, fmt.Errorf("rebase cancelled, HEAD is at %.6s", msg.Sha1)
+			}
+			return cv, false, err
+		}
+
+		reply := cv.Append(conv.ChatRoleAssistant, data)
+		fmt.Print(yellow(fmt.Sprintf(" [%.*s]\n", 6, reply.Sha1)))
+	}
+
+	return cv, false, nil
+}
+
+func printMessage(msg conv.Message) {
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	fmt.Printf("%s\n", yellow(fmt.Sprintf("%.*s [%s] -> %.*s", 6, msg.Sha1, msg.Role, 6, msg.ParentSha1)))
+	for _, context := range strings.Split(msg.Content, "\n") {
+		fmt.Printf("  %s\n", context)
+	}
+}
+
+// branch handles `:branch [name [sha1]]`. Without arguments it lists the named branches.
+func branch(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		branches := cv.GetBranches()
+		if len(branches) == 0 {
+			fmt.Printf("No named branches.\n")
+			return cv, false, nil
+		}
+
+		names := make([]string, 0, len(branches))
+		for name := range branches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("  %-16s %.6s\n", name, branches[name])
+		}
+		return cv, false, nil
+	}
+
+	name := strings.TrimSpace(args[0])
+	target := ""
+	if len(args) > 1 {
+		target = strings.TrimSpace(args[1])
+	}
+	if target == "" {
+		path := cv.MessagesFromHead()
+		if len(path) == 0 {
+			return nil, false, fmt.Errorf("no HEAD message to name")
+		}
+		target = path[len(path)-1].Sha1
+	}
+
+	msg, err := cv.SetBranch(name, target)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fmt.Printf("Branch %s -> [%.6s]\n", name, msg.Sha1)
+	return cv, false, nil
+}
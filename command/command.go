@@ -4,11 +4,11 @@ import (
 	"fmt"
 	"github.com/charmbracelet/glamour"
 	"github.com/fatih/color"
+	"github.com/kznrluk/aski/chat"
 	"github.com/kznrluk/aski/config"
 	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/util"
 	"os"
-	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
 )
@@ -42,6 +42,70 @@ var availableCommands = []cmd{
 		description: "Modify the past conversation. HEAD does not move.\n" +
 			"                   Past conversations will be modified from the next transmission.",
 	},
+	{
+		name: ":system",
+		description: "Open an external text editor to change the system prompt.\n" +
+			"                   The new prompt is recorded at HEAD and only applies to this branch.",
+	},
+	{
+		name:        ":title",
+		description: "Set the title of the conversation. Usage: :title [text]. Without text, the title is generated.",
+	},
+	{
+		name:        ":keep",
+		description: "Exempt the conversation from history gc. Usage: :keep [off]",
+	},
+	{
+		name: ":tag",
+		description: "List, add or remove tags. Usage: :tag [name...] [-name...]\n" +
+			"                   Tagged conversations are exempt from history gc.",
+	},
+	{
+		name: ":compact",
+		description: "Summarize older messages to save context. Usage: :compact [keep]\n" +
+			"                   The last keep messages (default 4) are kept as is.",
+	},
+	{
+		name: ":refresh",
+		description: "Reload the attached files that have changed. Usage: :refresh [--diff]\n" +
+			"                   Add --diff to send the changes as a new message instead.",
+	},
+	{
+		name:        ":cherry-pick",
+		description: "Copy a user/assistant pair from another branch onto HEAD.",
+	},
+	{
+		name: ":rebase",
+		description: "Replay a branch onto another message. Usage: :rebase sha1 onto sha1\n" +
+			"                   Add --reask to regenerate the assistant answers under the new context.",
+	},
+	{
+		name:        ":branch",
+		description: "List named branches, or name a branch. Usage: :branch name [sha1]",
+	},
+	{
+		name: ":delete",
+		description: "Delete a message. Usage: :delete sha1 [--subtree] [-y]\n" +
+			"                   Children are attached to the parent unless --subtree is given.",
+	},
+	{
+		name:        ":prune",
+		description: "Delete messages not reachable from HEAD or named branches.",
+	},
+	{
+		name: ":diff",
+		description: "Show the difference between two messages. Usage: :diff sha1 sha1 [--lines]\n" +
+			"                   Add --branch to compare the branches from their common ancestor.",
+	},
+	{
+		name:        ":export",
+		description: "Export the conversation to a file. Usage: :export md|html|json [--branch sha1|--all] [file]",
+	},
+	{
+		name: ":model",
+		description: "Switch the model from the next message. Usage: :model [name]\n" +
+			"                   The name may be an alias such as opus or 4o. Without a name, the models are listed.",
+	},
 	{
 		name: ":param",
 		description: "Update profile custom parameter values.\n" +
@@ -79,7 +143,7 @@ func unknownCommand() string {
 	return output
 }
 
-func Parse(input string, conv conv.Conversation) (conv.Conversation, bool, error) {
+func Parse(input string, conv conv.Conversation, cli chat.Chat) (conv.Conversation, bool, error) {
 	trimmedInput := strings.TrimSpace(input)
 	commands := strings.Split(trimmedInput, " ")
 
@@ -111,6 +175,34 @@ func Parse(input string, conv conv.Conversation) (conv.Conversation, bool, error
 		return editMessage(conv, trim)
 	} else if commands[0] == ":modify sha1" {
 		return modifyMessage(conv, commands[1])
+	} else if commands[0] == ":system" {
+		return changeSystem(conv)
+	} else if commands[0] == ":title" {
+		return title(conv, commands[1:])
+	} else if commands[0] == ":keep" {
+		return keep(conv, commands[1:])
+	} else if commands[0] == ":tag" {
+		return tag(conv, commands[1:])
+	} else if commands[0] == ":compact" {
+		return compact(conv, cli, commands[1:])
+	} else if commands[0] == ":refresh" {
+		return refresh(conv, commands[1:])
+	} else if commands[0] == ":cherry-pick" {
+		return cherryPick(conv, commands[1:])
+	} else if commands[0] == ":rebase" {
+		return rebase(conv, cli, commands[1:])
+	} else if commands[0] == ":branch" {
+		return branch(conv, commands[1:])
+	} else if commands[0] == ":delete" {
+		return deleteMessages(conv, commands[1:])
+	} else if commands[0] == ":prune" {
+		return prune(conv, commands[1:])
+	} else if commands[0] == ":diff" {
+		return diff(conv, commands[1:])
+	} else if commands[0] == ":export" {
+		return exportConversation(conv, commands[1:])
+	} else if commands[0] == ":model" {
+		return model(conv, commands[1:])
 	} else if commands[0] == ":param" {
 		if len(commands) < 3 {
 			if len(commands) == 2 {
@@ -161,7 +253,7 @@ func showContext(conv conv.Conversation) {
 
 	system := conv.GetSystem()
 	if system != "" {
-		fmt.Printf("%s\n", yellow("[System]"))
+		fmt.Printf("%s\n", yellow("[System] ROOT"))
 		out, err := r.Render(system)
 		if err != nil {
 			fmt.Printf("error: create markdown failed: %s", err.Error())
@@ -358,28 +450,8 @@ func openEditor(content string) (string, error) {
 		return "", fmt.Errorf("failed to write to the temp file: %v", err)
 	}
 
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		if runtime.GOOS == "windows" {
-			editor = "notepad.exe"
-		} else {
-			editor = "vim"
-		}
-	}
-
-	cmd := exec.Command(editor, tmpFile.Name())
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	// for vscode :)
-	if strings.Contains(editor, "code") {
-		cmd = exec.Command(editor, "--wait", tmpFile.Name())
-	}
-
-	err = cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("failed to open editor: %v", err)
+	if err := util.EditFile(tmpFile.Name()); err != nil {
+		return "", err
 	}
 
 	tmpFile.Close()
@@ -0,0 +1,110 @@
+package command
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/chat"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/session"
+	"github.com/kznrluk/aski/util"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultKeepMessages is the number of recent messages kept as is when compacting.
+	DefaultKeepMessages = 4
+
+	// compactThreshold is the share of the context window at which conversations are compacted automatically.
+	compactThreshold = 0.8
+
+	summarizePrompt = "You summarize conversations between a user and an AI assistant. " +
+		"Write a concise summary that keeps every fact, decision, code snippet and open question needed to continue the conversation. " +
+		"Answer with the summary only."
+)
+
+// NeedsCompaction reports whether the request for HEAD is close to the context window of the model in catalog.
+func NeedsCompaction(cv conv.Conversation, catalog config.Catalog) bool {
+	system, messages := cv.RequestFromHead()
+	tokens := util.EstimateTokens(system)
+	for _, m := range messages {
+		tokens += util.EstimateTokens(m.Content)
+	}
+
+	return float64(tokens) > float64(catalog.ContextWindow(cv.GetProfile().Model))*compactThreshold
+}
+
+// Compact asks the model to summarize the messages sent for HEAD, except for the last keep messages.
+// The summary replaces them in future requests, while the original messages stay in the tree.
+func Compact(cv conv.Conversation, cli chat.Chat, keep int) (conv.Message, error) {
+	_, messages := cv.RequestFromHead()
+
+	split := len(messages) - keep
+	if split < 0 {
+		split = 0
+	}
+	// Kept messages must start with the user, as some APIs require conversations to start with a user message.
+	for split > 0 && split < len(messages) && messages[split].Role != conv.ChatRoleUser {
+		split--
+	}
+	if split == 0 {
+		return conv.Message{}, fmt.Errorf("nothing to compact")
+	}
+
+	previous := ""
+	for _, m := range cv.MessagesFromHead() {
+		if m.Role == conv.ChatRoleSummary {
+			previous = m.Content
+		}
+	}
+
+	var sb strings.Builder
+	if previous != "" {
+		sb.WriteString(fmt.Sprintf("Summary of the conversation so far:\n%s\n\n", previous))
+	}
+	sb.WriteString("Conversation:\n\n")
+	for _, m := range messages[:split] {
+		sb.WriteString(fmt.Sprintf("%s: %s\n\n", m.Role, m.Content))
+	}
+
+	profile := cv.GetProfile()
+	profile.DiceRoll = ""
+	profile.ResponseFormat = "text"
+	request := conv.NewConversation(profile)
+	request.SetSystem(summarizePrompt)
+	request.Append(conv.ChatRoleUser, sb.String())
+
+	fmt.Printf("Summarizing %d messages...\n", split)
+	summary, err := cli.Retrieve(request, session.RestMode())
+	fmt.Printf("\n")
+	if err != nil {
+		return conv.Message{}, fmt.Errorf("failed to summarize: %w", err)
+	}
+
+	firstKept := ""
+	if split < len(messages) {
+		firstKept = messages[split].Sha1
+	}
+
+	return cv.Compact(summary, firstKept)
+}
+
+// compact handles `:compact [keep]`.
+func compact(cv conv.Conversation, cli chat.Chat, args []string) (conv.Conversation, bool, error) {
+	keep := DefaultKeepMessages
+	if len(args) > 0 && strings.TrimSpace(args[0]) != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(args[0]))
+		if err != nil || n < 0 {
+			return nil, false, fmt.Errorf("keep must be a non-negative number: %s", args[0])
+		}
+		keep = n
+	}
+
+	msg, err := Compact(cv, cli, keep)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fmt.Printf("[%.6s] Compacted. The original messages are kept in :history.\n", msg.Sha1)
+	return cv, false, nil
+}
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"fmt"
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+)
+
+// deleteMessages handles `:delete sha1 [--subtree] [-y]`.
+func deleteMessages(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	subtree := false
+	yes := false
+	sha1 := ""
+	for _, arg := range args {
+		switch strings.TrimSpace(arg) {
+		case "":
+			continue
+		case "--subtree":
+			subtree = true
+		case "-y", "--yes":
+			yes = true
+		default:
+			sha1 = strings.TrimSpace(arg)
+		}
+	}
+
+	if sha1 == "" {
+		return nil, false, fmt.Errorf("usage: :delete <sha1> [--subtree] [-y]")
+	}
+
+	var targets []conv.Message
+	if subtree {
+		messages, err := cv.Subtree(sha1)
+		if err != nil {
+			return nil, false, err
+		}
+		targets = messages
+	} else {
+		msg, err := cv.GetMessageFromSha1(sha1)
+		if err != nil {
+			return nil, false, err
+		}
+		targets = []conv.Message{msg}
+		if role := sameRoleAfterDelete(cv, msg); role != "" {
+			fmt.Printf("WARN: deleting %.6s leaves two %s messages in a row, which the APIs reject. Delete the next message too, or use --subtree.\n", msg.Sha1, role)
+		}
+	}
+
+	return removeMessages(cv, targets, yes)
+}
+
+// sameRoleAfterDelete returns the role of the user or assistant messages that would follow each other
+// if msg alone were deleted, or "" if there are none.
+func sameRoleAfterDelete(cv conv.Conversation, msg conv.Message) string {
+	parent, err := cv.GetMessageFromSha1(msg.ParentSha1)
+	if err != nil || (parent.Role != conv.ChatRoleUser && parent.Role != conv.ChatRoleAssistant) {
+		return ""
+	}
+	for _, m := range cv.GetMessages() {
+		if m.ParentSha1 == msg.Sha1 && m.Role == parent.Role {
+			return parent.Role
+		}
+	}
+	return ""
+}
+
+// prune handles `:prune [-y]`.
+func prune(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	yes := false
+	for _, arg := range args {
+		if arg == "-y" || arg == "--yes" {
+			yes = true
+		}
+	}
+
+	targets := cv.Unreachable()
+	if len(targets) == 0 {
+		fmt.Printf("Nothing to prune.\n")
+		return cv, false, nil
+	}
+
+	return removeMessages(cv, targets, yes)
+}
+
+func removeMessages(cv conv.Conversation, targets []conv.Message, yes bool) (conv.Conversation, bool, error) {
+	red := color.New(color.FgHiRed).SprintFunc()
+	fmt.Printf("The following %d message(s) will be deleted:\n", len(targets))
+	for _, m := range targets {
+		fmt.Printf("  %s %.48s\n", red(fmt.Sprintf("[%.*s] %s", 6, m.Sha1, m.Role)), strings.ReplaceAll(m.Content, "\n", " "))
+	}
+
+	if !yes {
+		confirmed := false
+		prompt := &survey.Confirm{
+			Message: "Delete these messages?",
+		}
+		if err := survey.AskOne(prompt, &confirmed); err != nil || !confirmed {
+			fmt.Printf("Aborted.\n")
+			return cv, false, nil
+		}
+	}
+
+	cv.Remove(targets)
+
+	head := "ROOT"
+	if path := cv.MessagesFromHead(); len(path) > 0 {
+		head = path[len(path)-1].Sha1
+	}
+	fmt.Printf("Deleted %d message(s). HEAD is at %.6s\n", len(targets), head)
+
+	return cv, false, nil
+}
@@ -0,0 +1,134 @@
+package command
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/util"
+	"strings"
+)
+
+// diff handles `:diff sha1 sha1 [--lines] [--branch]`.
+// Without --branch the contents of the two messages are compared. With --branch the paths to the two
+// messages are compared turn by turn from their common ancestor.
+func diff(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	lines := false
+	branch := false
+	var positional []string
+	for _, arg := range args {
+		switch strings.TrimSpace(arg) {
+		case "":
+			continue
+		case "--lines":
+			lines = true
+		case "--words":
+			lines = false
+		case "--branch":
+			branch = true
+		default:
+			positional = append(positional, strings.TrimSpace(arg))
+		}
+	}
+
+	if len(positional) != 2 {
+		return nil, false, fmt.Errorf("usage: :diff <sha1> <sha1> [--lines] [--branch]")
+	}
+
+	if !branch {
+		a, err := cv.GetMessageFromSha1(positional[0])
+		if err != nil {
+			return nil, false, err
+		}
+		b, err := cv.GetMessageFromSha1(positional[1])
+		if err != nil {
+			return nil, false, err
+		}
+
+		printDiffHeader(&a, &b)
+		printDiff(a.Content, b.Content, lines)
+		return nil, false, nil
+	}
+
+	d, err := cv.Diverge(positional[0], positional[1])
+	if err != nil {
+		return nil, false, err
+	}
+
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	if d.Ancestor == "ROOT" {
+		fmt.Printf("%s\n", yellow("Common ancestor: ROOT"))
+	} else {
+		ancestor, err := cv.GetMessageFromSha1(d.Ancestor)
+		if err != nil {
+			return nil, false, err
+		}
+		fmt.Printf("%s %.60s\n", yellow(fmt.Sprintf("Common ancestor: [%.*s] %s", 6, ancestor.Sha1, ancestor.Role)), firstLine(ancestor.Content))
+	}
+
+	for i := 0; i < len(d.A) || i < len(d.B); i++ {
+		fmt.Printf("\n")
+		switch {
+		case i < len(d.A) && i < len(d.B):
+			printDiffHeader(&d.A[i], &d.B[i])
+			printDiff(d.A[i].Content, d.B[i].Content, lines)
+		case i < len(d.A):
+			printDiffHeader(&d.A[i], nil)
+			printDiff(d.A[i].Content, "", lines)
+		default:
+			printDiffHeader(nil, &d.B[i])
+			printDiff("", d.B[i].Content, lines)
+		}
+	}
+
+	return nil, false, nil
+}
+
+func printDiffHeader(a *conv.Message, b *conv.Message) {
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	side := func(m *conv.Message) string {
+		if m == nil {
+			return "(none)"
+		}
+		return fmt.Sprintf("[%.*s] %s", 6, m.Sha1, m.Role)
+	}
+	fmt.Printf("%s\n", yellow(fmt.Sprintf("--- %s\n+++ %s", side(a), side(b))))
+}
+
+func printDiff(a string, b string, lines bool) {
+	red := color.New(color.FgHiRed).SprintFunc()
+	crossed := color.New(color.FgHiRed, color.CrossedOut).SprintFunc()
+	green := color.New(color.FgHiGreen).SprintFunc()
+
+	if lines {
+		for _, op := range util.Diff(util.SplitLines(a), util.SplitLines(b)) {
+			for _, line := range util.SplitLines(op.Text) {
+				line = strings.TrimSuffix(line, "\n")
+				switch op.Kind {
+				case util.DiffDelete:
+					fmt.Printf("%s\n", red("- "+line))
+				case util.DiffInsert:
+					fmt.Printf("%s\n", green("+ "+line))
+				default:
+					fmt.Printf("  %s\n", line)
+				}
+			}
+		}
+		return
+	}
+
+	for _, op := range util.Diff(util.SplitWords(a), util.SplitWords(b)) {
+		switch op.Kind {
+		case util.DiffDelete:
+			fmt.Print(crossed(op.Text))
+		case util.DiffInsert:
+			fmt.Print(green(op.Text))
+		default:
+			fmt.Print(op.Text)
+		}
+	}
+	fmt.Printf("\n")
+}
+
+func firstLine(content string) string {
+	return strings.SplitN(strings.TrimSpace(content), "\n", 2)[0]
+}
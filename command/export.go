@@ -0,0 +1,59 @@
+package command
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/export"
+	"os"
+	"strings"
+	"time"
+)
+
+// exportConversation handles `:export md|html|json [--branch sha1|--all] [file]`.
+func exportConversation(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	var opts export.Options
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimSpace(args[i])
+		switch arg {
+		case "":
+			continue
+		case "--all":
+			opts.All = true
+		case "--branch":
+			if i+1 >= len(args) {
+				return nil, false, fmt.Errorf("--branch requires a sha1 or branch name")
+			}
+			i++
+			opts.Branch = strings.TrimSpace(args[i])
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) == 0 {
+		return nil, false, fmt.Errorf("usage: :export md|html|json [--branch sha1|--all] [file]")
+	}
+
+	format, err := export.NormalizeFormat(positional[0])
+	if err != nil {
+		return nil, false, err
+	}
+
+	fileName := fmt.Sprintf("aski-%s.%s", time.Now().Format("20060102-150405"), format)
+	if len(positional) > 1 {
+		fileName = positional[1]
+	}
+
+	data, err := export.Render(cv, format, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := os.WriteFile(fileName, data, 0600); err != nil {
+		return nil, false, fmt.Errorf("failed to write %s: %v", fileName, err)
+	}
+
+	fmt.Printf("Exported to %s\n", fileName)
+	return cv, false, nil
+}
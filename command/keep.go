@@ -0,0 +1,54 @@
+package command
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+)
+
+// keep handles `:keep [off]`. Kept conversations are never deleted by `aski history gc`.
+func keep(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	switch strings.Join(args, " ") {
+	case "":
+		cv.SetKeep(true)
+		fmt.Printf("The conversation is kept by history gc.\n")
+	case "off":
+		cv.SetKeep(false)
+		fmt.Printf("The conversation is no longer kept by history gc.\n")
+	default:
+		return nil, false, fmt.Errorf("usage: :keep [off]")
+	}
+	return cv, false, nil
+}
+
+// tag handles `:tag [name...] [-name...]`, which adds tags, or removes them when prefixed with "-".
+// Without arguments, the tags are listed.
+func tag(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	tags := cv.GetTags()
+	removed := map[string]bool{}
+	for _, arg := range args {
+		if arg == "" {
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			removed[arg[1:]] = true
+			continue
+		}
+		tags = append(tags, arg)
+	}
+
+	var result []string
+	for _, t := range tags {
+		if !removed[t] {
+			result = append(result, t)
+		}
+	}
+	cv.SetTags(result)
+
+	if len(cv.GetTags()) == 0 {
+		fmt.Printf("No tags.\n")
+	} else {
+		fmt.Printf("Tags: %s\n", strings.Join(cv.GetTags(), ", "))
+	}
+	return cv, false, nil
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+)
+
+// model handles `:model [name]`, which switches the model of the conversation from the next message.
+// The name may be an alias of the catalog. Without a name, the current model and the catalog are listed.
+func model(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, false, err
+	}
+	catalog := cfg.Catalog()
+	profile := cv.GetProfile()
+
+	name := strings.TrimSpace(strings.Join(args, " "))
+	if name == "" {
+		fmt.Printf("Model: %s\n\n", profile.Model)
+		for _, m := range catalog {
+			aliases := ""
+			if len(m.Aliases) > 0 {
+				aliases = " (" + strings.Join(m.Aliases, ", ") + ")"
+			}
+			fmt.Printf("  %s%s\n", m.ID, aliases)
+		}
+		return cv, false, nil
+	}
+
+	profile.Model = catalog.Resolve(name)
+	if err := config.ValidateProfile(profile, catalog); err != nil {
+		return nil, false, err
+	}
+
+	if provider := catalog.Provider(profile.Model); provider != catalog.Provider(cv.GetProfile().Model) {
+		cfg, err := cfg.ResolveAPIKeys()
+		if err != nil {
+			return nil, false, err
+		}
+		if provider == config.ProviderAnthropic && cfg.AnthropicAPIKey == "" {
+			return nil, false, fmt.Errorf("AnthropicAPIKey is required for Claude models")
+		}
+		if provider == config.ProviderOpenAI && cfg.OpenAIAPIKey == "" {
+			return nil, false, fmt.Errorf("OpenAIAPIKey is required for GPT models")
+		}
+	}
+
+	if err := cv.SetProfile(profile); err != nil {
+		return nil, false, err
+	}
+	fmt.Printf("Model: %s\n", profile.Model)
+	return cv, false, nil
+}
@@ -0,0 +1,162 @@
+package command
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/file"
+	"github.com/kznrluk/aski/util"
+	"sort"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change of a file diff.
+const diffContextLines = 2
+
+func refresh(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	appendDiff := false
+	for _, arg := range args {
+		switch arg {
+		case "--diff":
+			appendDiff = true
+		case "":
+		default:
+			return nil, false, fmt.Errorf("usage: :refresh [--diff]")
+		}
+	}
+
+	changed, err := RefreshAttachments(cv, appendDiff)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(changed) == 0 {
+		fmt.Printf("Attached files are up to date.\n")
+		return cv, false, nil
+	}
+
+	for _, path := range changed {
+		fmt.Printf("Refresh File: %s\n", path)
+	}
+	return cv, false, nil
+}
+
+// RefreshAttachments re-reads the files attached on the path to HEAD and returns the paths of the changed ones.
+// Changed files are swapped into the messages that attached them, or attached again to HEAD if those messages
+// hold more than the file. With appendDiff, a message describing the changes is appended to HEAD instead.
+func RefreshAttachments(cv conv.Conversation, appendDiff bool) ([]string, error) {
+	files := cv.AttachedFilesFromHead()
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var changed []string
+	var diffs []string
+	var attachments []conv.Attachment
+	var appended []file.FileContents
+	for _, path := range paths {
+		attached := files[path]
+		f, err := file.Read(attached.Attachment.Path)
+		if err != nil {
+			fmt.Printf("WARN: cannot refresh %s: %v\n", attached.Attachment.Path, err)
+			continue
+		}
+
+		hash := conv.HashContents(f.Contents)
+		if hash == attached.Attachment.Hash {
+			continue
+		}
+		changed = append(changed, attached.Attachment.Path)
+
+		fresh := conv.Attachment{
+			Path:    attached.Attachment.Path,
+			Hash:    hash,
+			ModTime: f.ModTime,
+		}
+
+		if appendDiff {
+			fresh.Contents = f.Contents
+			attachments = append(attachments, fresh)
+			diffs = append(diffs, fmt.Sprintf("Path: `%s`\n```diff\n%s```", fresh.Path, FileDiff(attached.Contents, f.Contents)))
+			continue
+		}
+
+		// Only a message containing nothing but the file can be rewritten. The files of other messages,
+		// such as those describing the changes of several files, are attached again to HEAD.
+		content, ok := conv.ReplaceAttachmentContents(attached.Message.Content, f.Contents)
+		if !ok || len(attached.Message.Attachments) != 1 {
+			appended = append(appended, f)
+			continue
+		}
+
+		message, err := cv.GetMessageFromSha1(attached.Message.Sha1)
+		if err != nil {
+			return nil, err
+		}
+		message.Content = content
+		message.Attachments = []conv.Attachment{fresh}
+		if err := cv.Modify(message); err != nil {
+			return nil, err
+		}
+	}
+
+	if appendDiff && len(diffs) != 0 {
+		content := "The attached files have changed.\n\n" + strings.Join(diffs, "\n\n")
+		cv.AppendAttachments(content, attachments)
+	}
+	for _, f := range appended {
+		cv.AppendAttachment(f.Path, f.Contents, f.ModTime)
+	}
+
+	return changed, nil
+}
+
+// FileDiff returns a line diff of the two contents, eliding unchanged lines far from the changes.
+func FileDiff(a string, b string) string {
+	type line struct {
+		prefix string
+		text   string
+	}
+
+	var lines []line
+	for _, op := range util.Diff(util.SplitLines(a), util.SplitLines(b)) {
+		prefix := " "
+		switch op.Kind {
+		case util.DiffDelete:
+			prefix = "-"
+		case util.DiffInsert:
+			prefix = "+"
+		}
+		for _, l := range util.SplitLines(op.Text) {
+			lines = append(lines, line{prefix: prefix, text: strings.TrimSuffix(l, "\n")})
+		}
+	}
+
+	near := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.prefix == " " {
+			continue
+		}
+		for j := i - diffContextLines; j <= i+diffContextLines; j++ {
+			if j >= 0 && j < len(lines) {
+				near[j] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	elided := false
+	for i, l := range lines {
+		if !near[i] {
+			if !elided {
+				sb.WriteString("...\n")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		sb.WriteString(l.prefix + " " + l.text + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,29 @@
+package command
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+)
+
+// changeSystem opens the editor with the system prompt in effect at HEAD.
+// The edited prompt is recorded as a system message on HEAD, so other branches keep their own prompt.
+func changeSystem(cv conv.Conversation) (conv.Conversation, bool, error) {
+	current := cv.SystemFromHead()
+	comments := current + "\n\n# Edit the system prompt for this branch. Save and close editor to continue\n" +
+		"# The new prompt applies to messages after the current HEAD only.\n"
+
+	result, err := openEditor(comments)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open editor: %v", err)
+	}
+
+	if result == "" || strings.TrimSpace(result) == strings.TrimSpace(current) {
+		return cv, false, nil
+	}
+
+	msg := cv.Append(conv.ChatRoleSystem, result)
+	printMessage(msg)
+
+	return cv, false, nil
+}
@@ -0,0 +1,78 @@
+package command
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/chat"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+)
+
+const (
+	titlePrompt = "You name conversations between a user and an AI assistant. " +
+		"Answer with a title of at most six words in the language of the conversation, without quotes or punctuation at the end."
+
+	// titleMessageLength is the number of characters of each message sent to generate the title.
+	titleMessageLength = 1000
+)
+
+// GenerateTitle asks a cheap model for a title of the conversation up to HEAD and sets it.
+func GenerateTitle(cv conv.Conversation) (string, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return "", err
+	}
+	cfg, err = cfg.ResolveAPIKeys()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, m := range cv.MessagesFromHead() {
+		if m.Role != conv.ChatRoleUser && m.Role != conv.ChatRoleAssistant {
+			continue
+		}
+		content := m.Content
+		if r := []rune(content); len(r) > titleMessageLength {
+			content = string(r[:titleMessageLength])
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n\n", m.Role, content))
+	}
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("nothing to title yet")
+	}
+
+	profile := cv.GetProfile()
+	profile.Model = config.TitleModel(profile, cfg.Catalog())
+	profile.DiceRoll = ""
+	profile.ResponseFormat = "text"
+	profile.CustomParameters = config.CustomParameters{}
+	request := conv.NewConversation(profile)
+	request.SetSystem(titlePrompt)
+	request.Append(conv.ChatRoleUser, sb.String())
+
+	fmt.Printf("Title: ")
+	title, err := chat.ProvideChat(profile.Model, cfg).Retrieve(request, true)
+	fmt.Printf("\n")
+	if err != nil {
+		return "", fmt.Errorf("failed to generate title: %w", err)
+	}
+
+	cv.SetTitle(strings.Trim(strings.TrimSpace(title), "\"'"))
+	return cv.GetTitle(), nil
+}
+
+// title handles `:title [text]`. Without text, the title is generated.
+func title(cv conv.Conversation, args []string) (conv.Conversation, bool, error) {
+	text := strings.TrimSpace(strings.Join(args, " "))
+	if text == "" {
+		if _, err := GenerateTitle(cv); err != nil {
+			return nil, false, err
+		}
+		return cv, false, nil
+	}
+
+	cv.SetTitle(text)
+	fmt.Printf("Title: %s\n", cv.GetTitle())
+	return cv, false, nil
+}
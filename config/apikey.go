@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	EnvOpenAIAPIKey    = "OPENAI_API_KEY"
+	EnvAnthropicAPIKey = "ANTHROPIC_API_KEY"
+
+	// FileReferencePrefix marks an API key in config.yaml that is read from a file, such as "file:~/.secrets/openai".
+	FileReferencePrefix = "file:"
+)
+
+// APIKeyCommand holds commands that print an API key, such as "pass show openai".
+type APIKeyCommand struct {
+	OpenAI    string `yaml:"OpenAI,omitempty"`
+	Anthropic string `yaml:"Anthropic,omitempty"`
+}
+
+// APIKeySource describes where an API key was read from.
+type APIKeySource struct {
+	Name   string
+	Key    string
+	Source string
+}
+
+// commandOutputs caches the keys printed by commands, so each command runs once per process.
+var commandOutputs = map[string]string{}
+
+// ResolveAPIKeys returns the config with the API keys read from their sources, in this order:
+// the environment variable, APIKeyCommand, and the value in config.yaml, which may be a file: reference.
+// Do not Save the result, as it would write the keys to config.yaml.
+func (c Config) ResolveAPIKeys() (Config, error) {
+	sources, err := c.APIKeySources()
+	if err != nil {
+		return c, err
+	}
+	c.OpenAIAPIKey = sources[0].Key
+	c.AnthropicAPIKey = sources[1].Key
+	return c, nil
+}
+
+// APIKeySources resolves the API keys like ResolveAPIKeys and reports where each of them came from.
+func (c Config) APIKeySources() ([]APIKeySource, error) {
+	openAI, err := resolveAPIKey("OpenAIAPIKey", EnvOpenAIAPIKey, c.APIKeyCommand.OpenAI, c.OpenAIAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	anthropic, err := resolveAPIKey("AnthropicAPIKey", EnvAnthropicAPIKey, c.APIKeyCommand.Anthropic, c.AnthropicAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return []APIKeySource{openAI, anthropic}, nil
+}
+
+func resolveAPIKey(name string, env string, command string, value string) (APIKeySource, error) {
+	if key := strings.TrimSpace(os.Getenv(env)); key != "" {
+		return APIKeySource{Name: name, Key: key, Source: "environment variable " + env}, nil
+	}
+
+	if command != "" {
+		key, err := runAPIKeyCommand(command)
+		if err != nil {
+			return APIKeySource{}, fmt.Errorf("APIKeyCommand for %s failed: %w", name, err)
+		}
+		return APIKeySource{Name: name, Key: key, Source: "APIKeyCommand: " + command}, nil
+	}
+
+	if strings.HasPrefix(value, FileReferencePrefix) {
+		path := expandHome(strings.TrimSpace(strings.TrimPrefix(value, FileReferencePrefix)))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return APIKeySource{}, fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		return APIKeySource{Name: name, Key: strings.TrimSpace(string(data)), Source: "file " + path}, nil
+	}
+
+	if value != "" {
+		return APIKeySource{Name: name, Key: value, Source: "config.yaml"}, nil
+	}
+	return APIKeySource{Name: name, Source: "not set"}, nil
+}
+
+// runAPIKeyCommand runs the command with the shell and returns the first line it prints.
+func runAPIKeyCommand(command string) (string, error) {
+	if key, ok := commandOutputs[command]; ok {
+		return key, nil
+	}
+
+	out, err := runShell(command)
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.TrimSpace(strings.SplitN(out, "\n", 2)[0])
+	if key == "" {
+		return "", fmt.Errorf("%q printed nothing", command)
+	}
+	commandOutputs[command] = key
+	return key, nil
+}
+
+// runShell runs the command with the shell and returns what it prints.
+func runShell(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := GetHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// MaskAPIKey hides all but the start and the end of an API key.
+func MaskAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) < 12 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:3] + strings.Repeat("*", 8) + key[len(key)-4:]
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAPIKeySources(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "anthropic")
+	if err := os.WriteFile(keyFile, []byte("sk-ant-from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name           string
+		env            string
+		cfg            Config
+		expectedKey    string
+		expectedSource string
+	}{
+		{name: "Plain value", cfg: Config{OpenAIAPIKey: "sk-plain"}, expectedKey: "sk-plain", expectedSource: "config.yaml"},
+		{name: "Not set", cfg: Config{}, expectedKey: "", expectedSource: "not set"},
+		{name: "Environment wins", env: "sk-env", cfg: Config{OpenAIAPIKey: "sk-plain", APIKeyCommand: APIKeyCommand{OpenAI: "echo sk-command"}}, expectedKey: "sk-env", expectedSource: "environment variable OPENAI_API_KEY"},
+		{name: "Command wins over config", cfg: Config{OpenAIAPIKey: "sk-plain", APIKeyCommand: APIKeyCommand{OpenAI: "echo sk-command"}}, expectedKey: "sk-command", expectedSource: "APIKeyCommand: echo sk-command"},
+		{name: "File reference", cfg: Config{OpenAIAPIKey: "file:" + keyFile}, expectedKey: "sk-ant-from-file", expectedSource: "file " + keyFile},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && tc.cfg.APIKeyCommand.OpenAI != "" {
+				t.Skip("echo is a shell builtin")
+			}
+			t.Setenv(EnvOpenAIAPIKey, tc.env)
+
+			sources, err := tc.cfg.APIKeySources()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sources[0].Key != tc.expectedKey || sources[0].Source != tc.expectedSource {
+				t.Errorf("expected %q from %q, got %q from %q", tc.expectedKey, tc.expectedSource, sources[0].Key, sources[0].Source)
+			}
+		})
+	}
+}
+
+func TestAPIKeyCommandFailure(t *testing.T) {
+	t.Setenv(EnvAnthropicAPIKey, "")
+	cfg := Config{APIKeyCommand: APIKeyCommand{Anthropic: "exit 1"}}
+	if _, err := cfg.ResolveAPIKeys(); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestMaskAPIKey(t *testing.T) {
+	testCases := map[string]string{
+		"":                    "",
+		"short":               "*****",
+		"sk-1234567890abcdef": "sk-********cdef",
+	}
+	for key, expected := range testCases {
+		if got := MaskAPIKey(key); got != expected {
+			t.Errorf("MaskAPIKey(%q): expected %q, got %q", key, expected, got)
+		}
+	}
+}
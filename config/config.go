@@ -2,8 +2,10 @@ package config
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"github.com/goccy/go-yaml"
+	"github.com/kznrluk/aski/util"
 	"io"
 	"os"
 	"os/exec"
@@ -13,9 +15,31 @@ import (
 )
 
 type Config struct {
-	OpenAIAPIKey    string `yaml:"OpenAIAPIKey"`
-	AnthropicAPIKey string `yaml:"AnthropicAPIKey"`
-	CurrentProfile  string `yaml:"CurrentProfile"`
+	// OpenAIAPIKey and AnthropicAPIKey are the API keys, or file: followed by the path of a file containing one.
+	// The environment variables and APIKeyCommand take precedence, see ResolveAPIKeys.
+	OpenAIAPIKey    string        `yaml:"OpenAIAPIKey"`
+	AnthropicAPIKey string        `yaml:"AnthropicAPIKey"`
+	APIKeyCommand   APIKeyCommand `yaml:"APIKeyCommand,omitempty"`
+	CurrentProfile  string        `yaml:"CurrentProfile"`
+
+	// EncryptHistory encrypts new history files. Encrypted files are always decrypted when read.
+	EncryptHistory bool `yaml:"EncryptHistory,omitempty"`
+	// HistoryKeyFile is a file containing the passphrase for history files.
+	HistoryKeyFile string `yaml:"HistoryKeyFile,omitempty"`
+
+	// HistoryRetention limits how many conversations are kept. See `aski history gc`.
+	HistoryRetention Retention `yaml:"HistoryRetention,omitempty"`
+
+	// Overrides replace values of the profile for new conversations.
+	Overrides Overrides `yaml:"Overrides,omitempty"`
+
+	// TemplateShell enables {{shell "command"}} in the templates of profiles. See RenderProfile.
+	TemplateShell bool `yaml:"TemplateShell,omitempty"`
+	// TrustedProjects are the project directories whose profiles may use shell, file and env like global profiles.
+	TrustedProjects []string `yaml:"TrustedProjects,omitempty"`
+
+	// Models adds models to the built-in catalog, or replaces those with the same ID. See `aski models`.
+	Models []ModelInfo `yaml:"Models,omitempty"`
 }
 
 func InitialConfig() Config {
@@ -50,7 +74,13 @@ func MustGetAskiDir() string {
 	return filepath.Join(str, ".aski")
 }
 
+// MustGetHistoryDir returns the directory new conversations are saved to.
+// It is the history directory of the project if there is one, see HistoryDirs.
 func MustGetHistoryDir() string {
+	return HistoryDirs()[0]
+}
+
+func MustGetGlobalHistoryDir() string {
 	str := MustGetAskiDir()
 
 	return filepath.Join(str, "history")
@@ -77,72 +107,98 @@ func CreateInitialConfigFiles() error {
 		return err
 	}
 
-	err = os.WriteFile(configPath, data, 0600)
-	if err != nil {
+	// Another process may have created it in the meantime, which is just as good.
+	_, err = util.WriteFileChecked(configPath, data, 0600, util.Snapshot{})
+	if err != nil && !errors.Is(err, util.ErrConflict) {
 		return err
 	}
 
 	return nil
 }
 
-func Save(config Config) error {
+// Save writes config.yaml. expected is the snapshot returned by GetGlobalConfig with the config, so
+// changes by other processes since it was read are reported instead of overwritten. It returns the new snapshot.
+func Save(config Config, expected util.Snapshot) (util.Snapshot, error) {
 	yamlData, err := yaml.Marshal(config)
 	if err != nil {
-		return err
+		return util.Snapshot{}, err
 	}
 
 	configDir := MustGetAskiDir()
 
 	if err := os.MkdirAll(configDir, 0700); err != nil {
-		return err
+		return util.Snapshot{}, err
 	}
 
 	configPath := filepath.Join(configDir, "config.yaml")
-	err = os.WriteFile(configPath, yamlData, 0600)
-	if err != nil {
-		return err
+	saved, err := util.WriteFileChecked(configPath, yamlData, 0600, expected)
+	if errors.Is(err, util.ErrConflict) {
+		return util.Snapshot{}, fmt.Errorf("%w since it was read, run the command again", err)
+	} else if err != nil {
+		return util.Snapshot{}, err
 	}
-	return nil
+	return saved, nil
 }
 
+// GetConfig returns the effective configuration, see GetEffectiveConfig.
+// To change config.yaml, use GetGlobalConfig and Save instead, so values from other layers are not written to it.
 func GetConfig() (Config, error) {
+	cfg, _, err := GetEffectiveConfig()
+	return cfg, err
+}
+
+// GetGlobalConfig reads config.yaml in the aski directory, creating it on first use.
+// The snapshot of the file is passed to Save when the config is changed.
+func GetGlobalConfig() (Config, util.Snapshot, error) {
+	cfg, _, snapshot, err := readGlobalConfig()
+	return cfg, snapshot, err
+}
+
+func readGlobalConfig() (Config, map[string]interface{}, util.Snapshot, error) {
 	askiPath := MustGetAskiDir()
 
 	configPath := filepath.Join(askiPath, "config.yaml")
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		err := CreateInitialConfigFiles()
 		if err != nil {
-			return Config{}, err
+			return Config{}, nil, util.Snapshot{}, err
 		}
 	}
 
 	configFile, err := os.Open(configPath)
 	if err != nil {
-		return Config{}, err
+		return Config{}, nil, util.Snapshot{}, err
 	}
 
 	defer configFile.Close()
 
 	configBytes, err := io.ReadAll(configFile)
 	if err != nil {
-		return Config{}, err
+		return Config{}, nil, util.Snapshot{}, err
 	}
 
 	var config Config
 	err = yaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return Config{}, err
+		return Config{}, nil, util.Snapshot{}, err
 	}
+	warnUnknownKeys(configPath, configBytes, Config{})
+	snapshot := util.SnapshotOf(configBytes)
 
 	if config.CurrentProfile == "" {
 		config.CurrentProfile = GetDefaultProfileFileName()
-		err := Save(config)
+		snapshot, err = Save(config, snapshot)
 		if err != nil {
-			return Config{}, fmt.Errorf("failed to save config: %w", err)
+			return Config{}, nil, util.Snapshot{}, fmt.Errorf("failed to save config: %w", err)
 		}
 	}
 
-	return config, nil
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(configBytes, &values); err != nil {
+		return Config{}, nil, util.Snapshot{}, err
+	}
+	values["CurrentProfile"] = config.CurrentProfile
+	return config, values, snapshot, nil
 }
 
 func OpenConfigDir() bool {
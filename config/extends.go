@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"github.com/goccy/go-yaml"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveProfile returns the values of the profile file at path merged over the profiles it extends.
+// Maps such as CustomParameters are merged key by key, and other values replace those of the base.
+// Lists are replaced too, unless the key ends with "+", such as "Messages+", which appends to the list of the base.
+// stack holds the profiles extending this one, to detect cycles.
+func resolveProfile(path string, data []byte, stack []string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	for i, p := range stack {
+		if p == abs {
+			chain := append(stack[i:], abs)
+			for j := range chain {
+				chain[j] = filepath.Base(chain[j])
+			}
+			return nil, fmt.Errorf("profile Extends cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("cannot parse profile file %s: %s", path, err)
+	}
+
+	extends, _ := values["Extends"].(string)
+	if extends == "" {
+		merged, _ := MergeLayers([]Layer{{Values: values}})
+		return merged, nil
+	}
+
+	basePath, err := findBaseProfile(path, extends)
+	if err != nil {
+		return nil, err
+	}
+	baseData, err := os.ReadFile(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read profile file: %s", err)
+	}
+	// The base is migrated in memory only, it is written back when it is loaded itself.
+	migration, err := migrateProfileData(baseData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot migrate profile %s: %w", basePath, err)
+	}
+	base, err := resolveProfile(basePath, migration.Migrated, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, _ := MergeLayers([]Layer{{Values: base}, {Values: values}})
+	return merged, nil
+}
+
+// findBaseProfile finds the profile named in the Extends of the profile at path.
+// It is searched next to the extending profile first, and then in the profile directories.
+func findBaseProfile(path string, extends string) (string, error) {
+	var candidates []string
+	if filepath.IsAbs(extends) {
+		candidates = append(candidates, extends)
+	} else if strings.ContainsAny(extends, "/\\") {
+		candidates = append(candidates, filepath.Join(filepath.Dir(path), extends))
+	} else {
+		for _, dir := range append([]string{filepath.Dir(path)}, ProfileDirs()...) {
+			// The first path is relative to the working directory, which is not where the profile is.
+			candidates = append(candidates, createToSearchPaths(dir, Config{}, extends)[1:]...)
+		}
+	}
+
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("profile %s extends %s, which is not found", filepath.Base(path), extends)
+}
+
+func profileFromValues(values map[string]interface{}) (Profile, error) {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var profile Profile
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+// ExtendingProfiles returns the profiles in ProfileDirs whose Extends refers to the profile at path.
+func ExtendingProfiles(path string) ([]ProfileFile, error) {
+	target, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ProfileFile
+	for _, p := range profiles {
+		data, err := os.ReadFile(p.Path)
+		if err != nil {
+			continue
+		}
+		var header struct {
+			Extends string `yaml:"Extends"`
+		}
+		if yaml.Unmarshal(data, &header) != nil || header.Extends == "" {
+			continue
+		}
+		base, err := findBaseProfile(p.Path, header.Extends)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(base); err == nil && os.SameFile(info, target) {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeProfiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const baseProfile = `ProfileName: Base
+Model: gpt-4
+UserName: aski
+ResponseFormat: text
+SystemContext: You are a reviewer.
+Messages:
+  - Role: user
+    Content: hello
+CustomParameters:
+  temperature: 0.5
+  stop: ["END"]
+`
+
+func TestLoadProfileExtends(t *testing.T) {
+	dir := writeProfiles(t, map[string]string{
+		"base.yaml": baseProfile,
+		"append.yaml": `Extends: base.yaml
+ProfileName: Append
+Messages+:
+  - Role: assistant
+    Content: hi
+CustomParameters:
+  max_tokens: 100
+`,
+		"replace.yaml": `Extends: base
+ProfileName: Replace
+Model: claude-3-opus-20240229
+Messages:
+  - Role: user
+    Content: only this
+`,
+		"nested.yaml": `Extends: append
+SystemContext: You are a strict reviewer.
+`,
+	})
+
+	testCases := []struct {
+		file     string
+		expected Profile
+	}{
+		{
+			file: "append.yaml",
+			expected: Profile{
+				ProfileName: "Append", Model: "gpt-4", UserName: "aski", ResponseFormat: "text", SystemContext: "You are a reviewer.",
+				Messages:         []PreMessage{{Role: "user", Content: "hello"}, {Role: "assistant", Content: "hi"}},
+				CustomParameters: CustomParameters{Temperature: 0.5, MaxTokens: 100, Stop: []string{"END"}},
+			},
+		},
+		{
+			file: "replace.yaml",
+			expected: Profile{
+				ProfileName: "Replace", Model: "claude-3-opus-20240229", UserName: "aski", ResponseFormat: "text", SystemContext: "You are a reviewer.",
+				Messages:         []PreMessage{{Role: "user", Content: "only this"}},
+				CustomParameters: CustomParameters{Temperature: 0.5, Stop: []string{"END"}},
+			},
+		},
+		{
+			file: "nested.yaml",
+			expected: Profile{
+				ProfileName: "Append", Model: "gpt-4", UserName: "aski", ResponseFormat: "text", SystemContext: "You are a strict reviewer.",
+				Messages:         []PreMessage{{Role: "user", Content: "hello"}, {Role: "assistant", Content: "hi"}},
+				CustomParameters: CustomParameters{Temperature: 0.5, MaxTokens: 100, Stop: []string{"END"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			p, err := LoadProfile(filepath.Join(dir, tc.file), BuiltinCatalog())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(p, tc.expected) {
+				t.Errorf("Expected %+v, but got %+v", tc.expected, p)
+			}
+		})
+	}
+
+	// Extending profiles are not rewritten by the migration, which would copy the base into them.
+	data, err := os.ReadFile(filepath.Join(dir, "nested.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "Extends: append") {
+		t.Errorf("extending profile was rewritten:\n%s", data)
+	}
+}
+
+func TestLoadProfileExtendsErrors(t *testing.T) {
+	dir := writeProfiles(t, map[string]string{
+		"a.yaml":       "Extends: b.yaml\n",
+		"b.yaml":       "Extends: a\n",
+		"missing.yaml": "Extends: nowhere.yaml\n",
+	})
+
+	_, err := LoadProfile(filepath.Join(dir, "a.yaml"), BuiltinCatalog())
+	if err == nil || !strings.Contains(err.Error(), "a.yaml -> b.yaml -> a.yaml") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+
+	_, err = LoadProfile(filepath.Join(dir, "missing.yaml"), BuiltinCatalog())
+	if err == nil || !strings.Contains(err.Error(), "nowhere.yaml") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
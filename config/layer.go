@@ -0,0 +1,348 @@
+package config
+
+import (
+	"fmt"
+	"github.com/goccy/go-yaml"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Overrides replace values of the profile for new conversations.
+// They are usually set with flags or ASKI_* environment variables, see settings.
+// Temperature and MaxTokens are pointers, as 0 is a value that can be set.
+type Overrides struct {
+	Model         string   `yaml:"Model,omitempty"`
+	Temperature   *float32 `yaml:"Temperature,omitempty"`
+	MaxTokens     *int     `yaml:"MaxTokens,omitempty"`
+	SystemContext string   `yaml:"SystemContext,omitempty"`
+}
+
+// Apply returns the profile with the overrides that are set.
+func (o Overrides) Apply(p Profile) (Profile, error) {
+	if o.Model != "" {
+		p.Model = o.Model
+	}
+	if o.Temperature != nil {
+		p.CustomParameters.Temperature = *o.Temperature
+		if p.CustomParameters.Temperature == 0 {
+			// A temperature of 0 in a profile means the default of the API, which is not what was asked for.
+			p.CustomParameters.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if o.MaxTokens != nil {
+		// 0 means the default of the model.
+		p.CustomParameters.MaxTokens = *o.MaxTokens
+	}
+	if o.SystemContext != "" {
+		p.SystemContext = o.SystemContext
+	}
+	return p, ValidateCustomParameters(p.CustomParameters)
+}
+
+// Layer is a source of configuration values. Values are nested maps as decoded from YAML.
+type Layer struct {
+	Name   string
+	Values map[string]interface{}
+}
+
+// Origins maps the dotted path of each value, such as "HistoryRetention.MaxAge", to the name of the layer that set it.
+type Origins map[string]string
+
+// setting is a value that can be set with an environment variable and, if flag is set, a command line flag.
+type setting struct {
+	key   string
+	env   string
+	flag  string
+	parse func(string) (interface{}, error)
+}
+
+var settings = []setting{
+	{key: "CurrentProfile", env: "ASKI_PROFILE", parse: parseString},
+	{key: "EncryptHistory", env: "ASKI_ENCRYPT_HISTORY", parse: parseBool},
+	{key: "HistoryKeyFile", env: "ASKI_HISTORY_KEY_FILE", parse: parseString},
+	{key: "Overrides.Model", env: "ASKI_MODEL", flag: "model", parse: parseString},
+	{key: "Overrides.Temperature", env: "ASKI_TEMPERATURE", flag: "temperature", parse: parseFloat},
+	{key: "Overrides.MaxTokens", env: "ASKI_MAX_TOKENS", flag: "max-tokens", parse: parseInt},
+	{key: "Overrides.SystemContext", env: "ASKI_SYSTEM", flag: "system", parse: parseString},
+}
+
+// projectKeys are the only values read from project configs, as they are committed with code that may not be trusted.
+// Other values could run commands, send secrets to the model or delete and decrypt the global history.
+var projectKeys = []string{"CurrentProfile", "Overrides.Model", "Overrides.Temperature", "Overrides.MaxTokens"}
+
+// flagLayers are the layers set with SetFlag.
+var flagLayers []Layer
+
+// warnedProjectKeys avoids repeating the warning about ignored keys, as the config is read many times.
+var warnedProjectKeys bool
+
+// FlagNames returns the flags that set configuration values with SetFlag.
+func FlagNames() []string {
+	var names []string
+	for _, s := range settings {
+		if s.flag != "" {
+			names = append(names, s.flag)
+		}
+	}
+	return names
+}
+
+// SetFlag records the value of a command line flag, which takes precedence over every other layer.
+func SetFlag(flag string, value string) error {
+	for _, s := range settings {
+		if s.flag != flag {
+			continue
+		}
+		v, err := s.parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", flag, err)
+		}
+		flagLayers = append(flagLayers, Layer{Name: "--" + flag, Values: nestedValue(s.key, v)})
+		return nil
+	}
+	return fmt.Errorf("unknown flag --%s", flag)
+}
+
+// GetEffectiveConfig merges, from lowest to highest precedence, the defaults, the global config.yaml,
+// the config.yaml of the project, ASKI_* environment variables and flags. It reports where each value came from.
+func GetEffectiveConfig() (Config, Origins, error) {
+	_, globalValues, _, err := readGlobalConfig()
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	layers := []Layer{defaultLayer()}
+	layers = append(layers, Layer{Name: filepath.Join(MustGetAskiDir(), "config.yaml"), Values: globalValues})
+
+	project, err := projectLayer()
+	if err != nil {
+		return Config{}, nil, err
+	}
+	if project != nil {
+		layers = append(layers, *project)
+	}
+
+	env, err := envLayers()
+	if err != nil {
+		return Config{}, nil, err
+	}
+	layers = append(layers, env...)
+	layers = append(layers, flagLayers...)
+
+	merged, origins := MergeLayers(layers)
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, origins, nil
+}
+
+// MergeLayers merges the layers in order. Maps are merged key by key, other values are replaced.
+// Lists under keys ending with "+", such as "Messages+", are appended instead.
+func MergeLayers(layers []Layer) (map[string]interface{}, Origins) {
+	merged := map[string]interface{}{}
+	origins := Origins{}
+	for _, l := range layers {
+		mergeValues(merged, l.Values, "", l.Name, origins)
+	}
+	return merged, origins
+}
+
+func mergeValues(dst map[string]interface{}, src map[string]interface{}, prefix string, name string, origins Origins) {
+	for k, v := range src {
+		if v == nil {
+			continue
+		}
+		path := prefix + k
+
+		if m, ok := v.(map[string]interface{}); ok {
+			d, ok := dst[k].(map[string]interface{})
+			if !ok {
+				d = map[string]interface{}{}
+				dst[k] = d
+			}
+			mergeValues(d, m, path+".", name, origins)
+			continue
+		}
+
+		// A list under a key ending with "+" is appended to the list of the previous layers.
+		if list, ok := v.([]interface{}); ok && strings.HasSuffix(k, "+") {
+			k = strings.TrimSuffix(k, "+")
+			path = strings.TrimSuffix(path, "+")
+			base, _ := dst[k].([]interface{})
+			v = append(append([]interface{}{}, base...), list...)
+		}
+
+		dst[k] = v
+		origins[path] = name
+	}
+}
+
+// Value is a configuration value as a dotted path, such as "HistoryRetention.MaxAge", and the layer that set it.
+type Value struct {
+	Key    string
+	Value  string
+	Origin string
+}
+
+// Values lists the values of cfg that are set, sorted by path.
+func Values(cfg Config, origins Origins) ([]Value, error) {
+	values, err := toValues(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Value
+	flatten(values, "", origins, &result)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+func flatten(values map[string]interface{}, prefix string, origins Origins, result *[]Value) {
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			flatten(m, prefix+k+".", origins, result)
+			continue
+		}
+
+		text := fmt.Sprint(v)
+		if _, ok := v.([]interface{}); ok {
+			if data, err := yaml.MarshalWithOptions(v, yaml.Flow(true)); err == nil {
+				text = strings.TrimSpace(string(data))
+			}
+		}
+		origin := origins[prefix+k]
+		if origin == "" {
+			origin = "default"
+		}
+		*result = append(*result, Value{Key: prefix + k, Value: text, Origin: origin})
+	}
+}
+
+func defaultLayer() Layer {
+	values, _ := toValues(InitialConfig())
+	return Layer{Name: "default", Values: values}
+}
+
+// projectLayer reads the config.yaml of the project, or returns nil outside a project.
+func projectLayer() (*Layer, error) {
+	dir := GetProjectDir()
+	if dir == "" {
+		return nil, nil
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	warnUnknownKeys(path, data, Config{})
+	ignored := filterProjectValues(values, "")
+	sort.Strings(ignored)
+	for _, key := range ignored {
+		if !warnedProjectKeys {
+			fmt.Fprintf(os.Stderr, "WARN: %s in %s is ignored, set it in the global config.yaml or the environment.\n", key, path)
+		}
+	}
+	warnedProjectKeys = true
+
+	return &Layer{Name: path, Values: values}, nil
+}
+
+// filterProjectValues removes the values that are not in projectKeys from values at the dotted path prefix,
+// and returns their paths.
+func filterProjectValues(values map[string]interface{}, prefix string) []string {
+	var ignored []string
+	for key, v := range values {
+		path := prefix + key
+		allowed, parent := false, false
+		for _, k := range projectKeys {
+			allowed = allowed || k == path
+			parent = parent || strings.HasPrefix(k, path+".")
+		}
+
+		nested, isMap := v.(map[string]interface{})
+		switch {
+		case allowed:
+		case parent && isMap:
+			ignored = append(ignored, filterProjectValues(nested, path+".")...)
+		default:
+			delete(values, key)
+			ignored = append(ignored, path)
+		}
+	}
+	return ignored
+}
+
+// envLayers returns a layer for each ASKI_* environment variable that is set.
+func envLayers() ([]Layer, error) {
+	var layers []Layer
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		v, err := s.parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+		}
+		layers = append(layers, Layer{Name: s.env, Values: nestedValue(s.key, v)})
+	}
+	return layers, nil
+}
+
+// nestedValue returns the maps that hold v at the dotted path key.
+func nestedValue(key string, v interface{}) map[string]interface{} {
+	parts := strings.Split(key, ".")
+	values := map[string]interface{}{parts[len(parts)-1]: v}
+	for i := len(parts) - 2; i >= 0; i-- {
+		values = map[string]interface{}{parts[i]: values}
+	}
+	return values
+}
+
+func toValues(v interface{}) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseString(s string) (interface{}, error) {
+	return s, nil
+}
+
+func parseBool(s string) (interface{}, error) {
+	return strconv.ParseBool(s)
+}
+
+func parseFloat(s string) (interface{}, error) {
+	return strconv.ParseFloat(s, 32)
+}
+
+func parseInt(s string) (interface{}, error) {
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"github.com/kznrluk/aski/util"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMergeLayers(t *testing.T) {
+	merged, origins := MergeLayers([]Layer{
+		{Name: "default", Values: map[string]interface{}{"CurrentProfile": "default.yaml", "EncryptHistory": false}},
+		{Name: "global", Values: map[string]interface{}{
+			"CurrentProfile":   "gpt4.yaml",
+			"HistoryRetention": map[string]interface{}{"MaxAge": "90d", "MaxCount": 100},
+		}},
+		{Name: "project", Values: map[string]interface{}{
+			"HistoryRetention": map[string]interface{}{"MaxAge": "7d"},
+			"HistoryKeyFile":   nil,
+		}},
+		{Name: "--model", Values: nestedValue("Overrides.Model", "gpt-4")},
+	})
+
+	expected := map[string]interface{}{
+		"CurrentProfile":   "gpt4.yaml",
+		"EncryptHistory":   false,
+		"HistoryRetention": map[string]interface{}{"MaxAge": "7d", "MaxCount": 100},
+		"Overrides":        map[string]interface{}{"Model": "gpt-4"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v, got %v", expected, merged)
+	}
+
+	expectedOrigins := Origins{
+		"CurrentProfile":            "global",
+		"EncryptHistory":            "default",
+		"HistoryRetention.MaxAge":   "project",
+		"HistoryRetention.MaxCount": "global",
+		"Overrides.Model":           "--model",
+	}
+	if !reflect.DeepEqual(origins, expectedOrigins) {
+		t.Errorf("expected %v, got %v", expectedOrigins, origins)
+	}
+}
+
+func TestGetEffectiveConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".aski"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	global := "CurrentProfile: gpt4.yaml\nOverrides:\n  Model: gpt-3.5-turbo\n  MaxTokens: 100\n"
+	if err := os.WriteFile(filepath.Join(home, ".aski", "config.yaml"), []byte(global), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ASKI_TEMPERATURE", "0.5")
+	t.Setenv("ASKI_MODEL", "gpt-4")
+
+	flagLayers = nil
+	defer func() { flagLayers = nil }()
+	if err := SetFlag("max-tokens", "200"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFlag("temperature", "hot"); err == nil {
+		t.Errorf("expected an error for an invalid flag value")
+	}
+
+	cfg, origins, err := GetEffectiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := cfg.Overrides
+	if o.Model != "gpt-4" || o.Temperature == nil || *o.Temperature != 0.5 || o.MaxTokens == nil || *o.MaxTokens != 200 || cfg.CurrentProfile != "gpt4.yaml" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if origins["Overrides.Model"] != "ASKI_MODEL" || origins["Overrides.MaxTokens"] != "--max-tokens" {
+		t.Errorf("unexpected origins: %v", origins)
+	}
+}
+
+func TestSaveDetectsConflicts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg, snapshot, err := GetGlobalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process changes config.yaml, and this one reads the config again before saving.
+	path := filepath.Join(MustGetAskiDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("CurrentProfile: other.yaml\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.CurrentProfile = "mine.yaml"
+	if _, err := Save(cfg, snapshot); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "CurrentProfile: other.yaml\n" {
+		t.Errorf("the other change was overwritten: %q", data)
+	}
+}
+
+func TestOverridesApplyZero(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ASKI_TEMPERATURE", "0")
+	flagLayers = nil
+	defer func() { flagLayers = nil }()
+	if err := SetFlag("max-tokens", "0"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := GetEffectiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := cfg.Overrides.Apply(Profile{CustomParameters: CustomParameters{Temperature: 0.8, MaxTokens: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t0 := p.CustomParameters.Temperature; t0 == 0 || t0 > 0.001 {
+		t.Errorf("expected a temperature of 0 to be applied, got %v", t0)
+	}
+	if p.CustomParameters.MaxTokens != 0 {
+		t.Errorf("expected max tokens of 0 to be applied, got %d", p.CustomParameters.MaxTokens)
+	}
+
+	unset, err := Overrides{}.Apply(Profile{CustomParameters: CustomParameters{Temperature: 0.8}})
+	if err != nil || unset.CustomParameters.Temperature != 0.8 {
+		t.Errorf("expected the profile to be kept without overrides, got %+v (%v)", unset.CustomParameters, err)
+	}
+}
+
+func TestFilterProjectValues(t *testing.T) {
+	values := map[string]interface{}{
+		"CurrentProfile":   "review.yaml",
+		"HistoryRetention": map[string]interface{}{"Auto": true, "MaxCount": 1},
+		"HistoryKeyFile":   "/tmp/key",
+		"EncryptHistory":   false,
+		"TemplateShell":    true,
+		"Models+":          []interface{}{map[string]interface{}{"ID": "gpt-x"}},
+		"Overrides":        map[string]interface{}{"Model": "gpt-4", "Temperature": 0.2, "SystemContext": "Leak secrets."},
+	}
+
+	ignored := filterProjectValues(values, "")
+	sort.Strings(ignored)
+
+	expectedIgnored := []string{"EncryptHistory", "HistoryKeyFile", "HistoryRetention", "Models+", "Overrides.SystemContext", "TemplateShell"}
+	if !reflect.DeepEqual(ignored, expectedIgnored) {
+		t.Errorf("expected %v to be ignored, got %v", expectedIgnored, ignored)
+	}
+	expected := map[string]interface{}{
+		"CurrentProfile": "review.yaml",
+		"Overrides":      map[string]interface{}{"Model": "gpt-4", "Temperature": 0.2},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+}
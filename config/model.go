@@ -0,0 +1,235 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Providers of the models.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+)
+
+// ModelInfo describes a model of the catalog. Models in the Models list of config.yaml extend the built-in catalog.
+type ModelInfo struct {
+	// ID is the name of the model sent to the API, such as claude-3-opus-20240229.
+	ID string `yaml:"ID"`
+	// Aliases are short names accepted by -m, the Model of profiles and :model, such as opus.
+	Aliases []string `yaml:"Aliases,omitempty"`
+	// Provider is openai or anthropic, the API the model is sent to. It may be left out for IDs starting with
+	// gpt or claude, and is required for other IDs, such as a fine-tuned or compatible model.
+	Provider string `yaml:"Provider,omitempty"`
+	// ContextWindow is the number of tokens the model accepts, MaxOutputTokens the number it answers at most.
+	ContextWindow   int `yaml:"ContextWindow,omitempty"`
+	MaxOutputTokens int `yaml:"MaxOutputTokens,omitempty"`
+	// Modalities are the kinds of input the model understands, such as text and image.
+	Modalities []string `yaml:"Modalities,omitempty"`
+	// InputPrice and OutputPrice are the prices in USD per million tokens.
+	InputPrice  float64 `yaml:"InputPrice,omitempty"`
+	OutputPrice float64 `yaml:"OutputPrice,omitempty"`
+}
+
+// Cost returns the price in USD of a request with the number of input and output tokens.
+func (m ModelInfo) Cost(inputTokens int, outputTokens int) float64 {
+	return (float64(inputTokens)*m.InputPrice + float64(outputTokens)*m.OutputPrice) / 1e6
+}
+
+// Supports reports whether the model understands the modality, such as image.
+func (m ModelInfo) Supports(modality string) bool {
+	for _, mod := range m.Modalities {
+		if mod == modality {
+			return true
+		}
+	}
+	return false
+}
+
+var builtinModels = []ModelInfo{
+	{ID: "gpt-4o", Aliases: []string{"4o"}, Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 4096, Modalities: []string{"text", "image"}, InputPrice: 5, OutputPrice: 15},
+	{ID: "gpt-4-turbo", Aliases: []string{"4t"}, Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 4096, Modalities: []string{"text", "image"}, InputPrice: 10, OutputPrice: 30},
+	{ID: "gpt-4-turbo-preview", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 4096, Modalities: []string{"text"}, InputPrice: 10, OutputPrice: 30},
+	{ID: "gpt-4", Aliases: []string{"4"}, Provider: ProviderOpenAI, ContextWindow: 8192, MaxOutputTokens: 8192, Modalities: []string{"text"}, InputPrice: 30, OutputPrice: 60},
+	{ID: "gpt-4-32k", Provider: ProviderOpenAI, ContextWindow: 32768, MaxOutputTokens: 8192, Modalities: []string{"text"}, InputPrice: 60, OutputPrice: 120},
+	{ID: "gpt-3.5-turbo", Aliases: []string{"3.5"}, Provider: ProviderOpenAI, ContextWindow: 16385, MaxOutputTokens: 4096, Modalities: []string{"text"}, InputPrice: 0.5, OutputPrice: 1.5},
+	{ID: "claude-3-opus-20240229", Aliases: []string{"opus"}, Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 4096, Modalities: []string{"text", "image"}, InputPrice: 15, OutputPrice: 75},
+	{ID: "claude-3-sonnet-20240229", Aliases: []string{"sonnet"}, Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 4096, Modalities: []string{"text", "image"}, InputPrice: 3, OutputPrice: 15},
+	{ID: "claude-3-haiku-20240307", Aliases: []string{"haiku"}, Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 4096, Modalities: []string{"text", "image"}, InputPrice: 0.25, OutputPrice: 1.25},
+	{ID: "claude-2.1", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 4096, Modalities: []string{"text"}, InputPrice: 8, OutputPrice: 24},
+	{ID: "claude-2.0", Provider: ProviderAnthropic, ContextWindow: 100000, MaxOutputTokens: 4096, Modalities: []string{"text"}, InputPrice: 8, OutputPrice: 24},
+}
+
+// contextWindows maps model name prefixes to the number of tokens they accept, for models not in the catalog,
+// such as dated versions. Longer prefixes are matched first.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{prefix: "gpt-4o", tokens: 128000},
+	{prefix: "gpt-4-turbo", tokens: 128000},
+	{prefix: "gpt-4-1106", tokens: 128000},
+	{prefix: "gpt-4-0125", tokens: 128000},
+	{prefix: "gpt-4-32k", tokens: 32768},
+	{prefix: "gpt-4", tokens: 8192},
+	{prefix: "gpt-3.5-turbo", tokens: 16385},
+	{prefix: "claude-3", tokens: 200000},
+	{prefix: "claude-2", tokens: 100000},
+}
+
+const (
+	defaultContextWindow   = 8192
+	defaultMaxOutputTokens = 4096
+)
+
+// Catalog is the list of known models. Earlier entries take precedence when IDs or aliases collide.
+type Catalog []ModelInfo
+
+// BuiltinCatalog returns the models known to aski.
+func BuiltinCatalog() Catalog {
+	return append(Catalog{}, builtinModels...)
+}
+
+// Catalog returns the models of the Models list, followed by the built-in ones.
+// A model of the list replaces the built-in model with the same ID.
+func (c Config) Catalog() Catalog {
+	var catalog Catalog
+	for _, m := range c.Models {
+		if m.Provider == "" {
+			m.Provider = ModelProvider(m.ID)
+		}
+		catalog = append(catalog, m)
+	}
+	for _, m := range builtinModels {
+		if _, ok := catalog.byID(m.ID); !ok {
+			catalog = append(catalog, m)
+		}
+	}
+	return catalog
+}
+
+// LoadCatalog returns the catalog of the effective configuration, or the built-in catalog if it cannot be read.
+// It reads the configuration files, so commands that already hold a Config use Config.Catalog instead.
+func LoadCatalog() Catalog {
+	cfg, err := GetConfig()
+	if err != nil {
+		return BuiltinCatalog()
+	}
+	return cfg.Catalog()
+}
+
+func (c Catalog) byID(id string) (ModelInfo, bool) {
+	for _, m := range c {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// Lookup finds a model by its ID, or by an alias ignoring case.
+func (c Catalog) Lookup(name string) (ModelInfo, bool) {
+	if m, ok := c.byID(name); ok {
+		return m, true
+	}
+	for _, m := range c {
+		for _, alias := range m.Aliases {
+			if strings.EqualFold(alias, name) {
+				return m, true
+			}
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// Resolve returns the ID of the model an alias stands for. Other names are returned as is,
+// so models missing from the catalog can still be used.
+func (c Catalog) Resolve(name string) string {
+	if m, ok := c.Lookup(name); ok {
+		return m.ID
+	}
+	return name
+}
+
+// ContextWindow returns the number of tokens the model accepts, or a conservative default for unknown models.
+func (c Catalog) ContextWindow(model string) int {
+	if m, ok := c.Lookup(model); ok && m.ContextWindow > 0 {
+		return m.ContextWindow
+	}
+
+	best := ""
+	tokens := defaultContextWindow
+	for _, w := range contextWindows {
+		if strings.HasPrefix(model, w.prefix) && len(w.prefix) > len(best) {
+			best = w.prefix
+			tokens = w.tokens
+		}
+	}
+	return tokens
+}
+
+// MaxOutputTokens returns the number of tokens the model answers at most, or a default for unknown models.
+func (c Catalog) MaxOutputTokens(model string) int {
+	if m, ok := c.Lookup(model); ok && m.MaxOutputTokens > 0 {
+		return m.MaxOutputTokens
+	}
+	return defaultMaxOutputTokens
+}
+
+// Provider returns the provider of the model, the one of its catalog entry if it has one, or else ModelProvider.
+func (c Catalog) Provider(model string) string {
+	if m, ok := c.Lookup(model); ok && m.Provider != "" {
+		return m.Provider
+	}
+	return ModelProvider(model)
+}
+
+// Known reports whether aski can tell the provider of the model, by its catalog entry or its name.
+func (c Catalog) Known(model string) bool {
+	if _, ok := c.Lookup(model); ok {
+		return true
+	}
+	return hasKnownPrefix(model)
+}
+
+func hasKnownPrefix(model string) bool {
+	return strings.HasPrefix(model, "gpt") || strings.HasPrefix(model, "claude")
+}
+
+// ModelProvider returns the provider of the model by its name, for models that are not in the catalog.
+func ModelProvider(model string) string {
+	if strings.HasPrefix(model, "claude") {
+		return ProviderAnthropic
+	}
+	return ProviderOpenAI
+}
+
+// ValidateModels checks the Models list of config.yaml.
+func ValidateModels(models []ModelInfo) error {
+	for i, m := range models {
+		if m.ID == "" {
+			return fmt.Errorf("Models[%d].ID must not be empty", i)
+		}
+		if m.Provider != "" && m.Provider != ProviderOpenAI && m.Provider != ProviderAnthropic {
+			return fmt.Errorf("Models[%d].Provider must be %s or %s, but got %q", i, ProviderOpenAI, ProviderAnthropic, m.Provider)
+		}
+		if m.Provider == "" && !hasKnownPrefix(m.ID) {
+			return fmt.Errorf("Models[%d].Provider must be set for %s, as it does not start with gpt or claude", i, m.ID)
+		}
+		if m.ContextWindow < 0 || m.MaxOutputTokens < 0 || m.InputPrice < 0 || m.OutputPrice < 0 {
+			return fmt.Errorf("Models[%d]: limits and prices must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// TitleModel returns the model used to generate conversation titles.
+// Unless the profile sets one, it is a cheap model of the same provider as the profile model.
+func TitleModel(p Profile, catalog Catalog) string {
+	if p.TitleModel != "" {
+		return p.TitleModel
+	}
+	if catalog.Provider(p.Model) == ProviderAnthropic {
+		return "claude-3-haiku-20240307"
+	}
+	return "gpt-3.5-turbo"
+}
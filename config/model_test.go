@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogResolve(t *testing.T) {
+	catalog := Config{Models: []ModelInfo{
+		{ID: "gpt-4o-mini", Aliases: []string{"mini"}},
+		{ID: "claude-3-opus-20240229", Aliases: []string{"opus", "big"}, ContextWindow: 100000},
+	}}.Catalog()
+
+	names := map[string]string{
+		"opus":                   "claude-3-opus-20240229",
+		"OPUS":                   "claude-3-opus-20240229",
+		"big":                    "claude-3-opus-20240229",
+		"4o":                     "gpt-4o",
+		"mini":                   "gpt-4o-mini",
+		"gpt-4":                  "gpt-4",
+		"gpt-4-0613":             "gpt-4-0613",
+		"claude-3-opus-20240229": "claude-3-opus-20240229",
+	}
+	for name, expected := range names {
+		if got := catalog.Resolve(name); got != expected {
+			t.Errorf("Resolve(%q): expected %q, got %q", name, expected, got)
+		}
+	}
+
+	if m, _ := catalog.Lookup("mini"); m.Provider != ProviderOpenAI {
+		t.Errorf("expected the provider of gpt-4o-mini to follow from its ID, got %q", m.Provider)
+	}
+	if n := len(catalog); n != len(builtinModels)+1 {
+		t.Errorf("expected the configured opus to replace the built-in one, got %d models", n)
+	}
+}
+
+func TestCatalogProvider(t *testing.T) {
+	catalog := Config{Models: []ModelInfo{
+		{ID: "ft:gpt-3.5-turbo-0125:my-org::abc123", Aliases: []string{"tuned"}, Provider: ProviderOpenAI},
+		{ID: "proxy-claude", Provider: ProviderAnthropic},
+	}}.Catalog()
+
+	providers := map[string]string{
+		"tuned":          ProviderOpenAI,
+		"proxy-claude":   ProviderAnthropic,
+		"claude-2.1":     ProviderAnthropic,
+		"claude-unknown": ProviderAnthropic,
+		"gpt-unknown":    ProviderOpenAI,
+	}
+	for model, expected := range providers {
+		if got := catalog.Provider(model); got != expected {
+			t.Errorf("Provider(%q): expected %q, got %q", model, expected, got)
+		}
+	}
+
+	profile := InitialProfile()
+	profile.Model = "proxy-claude"
+	if err := ValidateProfile(profile, catalog); err != nil {
+		t.Errorf("expected a model of the catalog to be valid, got %v", err)
+	}
+	profile.Model = "llama-3"
+	if err := ValidateProfile(profile, catalog); err == nil {
+		t.Errorf("expected an error for a model with an unknown provider")
+	}
+}
+
+func TestCatalogLimits(t *testing.T) {
+	catalog := Config{Models: []ModelInfo{{ID: "gpt-4o-mini", MaxOutputTokens: 16384}}}.Catalog()
+
+	windows := map[string]int{
+		"opus":               200000,
+		"gpt-4-0613":         8192,
+		"gpt-4-turbo-2024":   128000,
+		"claude-2.0":         100000,
+		"gpt-4o-mini":        128000,
+		"some-unknown-model": defaultContextWindow,
+	}
+	for model, expected := range windows {
+		if got := catalog.ContextWindow(model); got != expected {
+			t.Errorf("ContextWindow(%q): expected %d, got %d", model, expected, got)
+		}
+	}
+
+	if got := catalog.MaxOutputTokens("gpt-4o-mini"); got != 16384 {
+		t.Errorf("expected 16384 output tokens, got %d", got)
+	}
+	if got := catalog.MaxOutputTokens("claude-3-opus-99999999"); got != defaultMaxOutputTokens {
+		t.Errorf("expected the default output tokens for an unknown model, got %d", got)
+	}
+
+	opus, _ := catalog.Lookup("opus")
+	if got := opus.Cost(1000000, 100000); got != 22.5 {
+		t.Errorf("expected a cost of 22.5, got %v", got)
+	}
+	if !opus.Supports("image") || opus.Supports("audio") {
+		t.Errorf("unexpected modalities %v", opus.Modalities)
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	valid := []ModelInfo{
+		{ID: "gpt-4o-mini"},
+		{ID: "claude-3-5-sonnet-20240620", Provider: ProviderAnthropic},
+		{ID: "ft:gpt-3.5-turbo-0125:my-org::abc123", Provider: ProviderOpenAI},
+	}
+	if err := ValidateModels(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, m := range []ModelInfo{
+		{},
+		{ID: "llama-3"},
+		{ID: "gpt-4o-mini", Provider: "azure"},
+		{ID: "gpt-4o-mini", InputPrice: -1},
+	} {
+		if err := ValidateModels([]ModelInfo{m}); err == nil {
+			t.Errorf("expected an error for %+v", m)
+		}
+	}
+}
+
+func TestLoadProfileResolvesAlias(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.yaml")
+	if err := os.WriteFile(path, []byte("ProfileName: p\nUserName: me\nModel: opus\nSystemContext: hi\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadProfile(path, BuiltinCatalog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Model != "claude-3-opus-20240229" {
+		t.Errorf("expected the alias to be resolved, got %q", p.Model)
+	}
+}
@@ -4,20 +4,29 @@ import (
 	"errors"
 	"fmt"
 	"github.com/goccy/go-yaml"
+	"github.com/kznrluk/aski/util"
 	"github.com/sashabaranov/go-openai"
 	"io"
 	"os"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 type Profile struct {
+	// ProfileVersion is the version of the layout of the profile file, see CurrentProfileVersion.
+	ProfileVersion int `yaml:"ProfileVersion,omitempty"`
+	// Extends is the profile this one is based on, see resolveProfile.
+	Extends          string           `yaml:"Extends,omitempty"`
 	ProfileName      string           `yaml:"ProfileName"`
 	Model            string           `yaml:"Model"`
 	UserName         string           `yaml:"UserName"`
 	AutoSave         bool             `yaml:"AutoSave"`
+	Summarize        bool             `yaml:"Summarize,omitempty"`
+	AutoTitle        bool             `yaml:"AutoTitle,omitempty"`
+	TitleModel       string           `yaml:"TitleModel,omitempty"`
 	ResponseFormat   string           `yaml:"ResponseFormat"`
 	SystemContext    string           `yaml:"SystemContext"`
 	Messages         []PreMessage     `yaml:"Messages"`
@@ -55,60 +64,110 @@ func GetDefaultProfileFileName() string {
 }
 
 func GetProfile(cfg Config, overload string) (Profile, error) {
+	target, err := FindProfile(cfg, overload)
+	if err != nil {
+		return Profile{}, err
+	}
+	return LoadProfile(target, cfg.Catalog())
+}
+
+// FindProfile returns the path of the profile named by overload, or of the current profile if overload is empty.
+func FindProfile(cfg Config, overload string) (string, error) {
 	if !hasDefaultProfile() {
 		err := CreateInitialProfileFile()
 		if err != nil {
-			return Profile{}, fmt.Errorf("cannot create initial profile file: %s", err)
+			return "", fmt.Errorf("cannot create initial profile file: %s", err)
 		}
 	}
 	// We called hasDefaultProfile() above, so we know that the default profile exists
-	profileDir := MustGetProfileDir()
-	toSearchPaths := createToSearchPaths(profileDir, cfg, overload)
+	toSearchPaths := profileSearchPaths(cfg, overload)
 
 	for _, target := range toSearchPaths {
 		if _, err := os.Stat(target); os.IsNotExist(err) {
 			continue
 		}
+		return target, nil
+	}
 
-		profileFile, err := os.Open(target)
-		if err != nil {
-			return Profile{}, fmt.Errorf("cannot open profile file: %s", err)
-		}
-		defer profileFile.Close()
+	return "", fmt.Errorf("profile file not found, tried: %s", strings.Join(toSearchPaths, ", "))
+}
 
-		profileBytes, err := io.ReadAll(profileFile)
-		if err != nil {
-			return Profile{}, fmt.Errorf("cannot read profile file: %s", err)
-		}
+// LoadProfile reads the profile at target, resolving Extends and the alias of the model in catalog, and validates it.
+// If the profile is from an older version of aski and in the global profile directory, the migrated profile is
+// written back to the file. Other profiles, such as those of a project, are migrated in memory only,
+// see `aski profile migrate`.
+func LoadProfile(target string, catalog Catalog) (Profile, error) {
+	return loadProfile(target, catalog, true)
+}
 
-		var profile Profile
-		err = yaml.Unmarshal(profileBytes, &profile)
-		if err != nil {
-			return Profile{}, fmt.Errorf("cannot parse profile file: %s", err)
-		}
+// ReadProfile is LoadProfile without writing the migrated profile back, for commands that only inspect profiles.
+func ReadProfile(target string, catalog Catalog) (Profile, error) {
+	return loadProfile(target, catalog, false)
+}
 
-		migrated, changed := migrateProfile(profile)
-		if changed {
-			profileData, err := yaml.Marshal(migrated)
-			if err != nil {
-				return Profile{}, fmt.Errorf("cannot marshal profile file: %s", err)
-			}
+func loadProfile(target string, catalog Catalog, writeMigrated bool) (Profile, error) {
+	profileFile, err := os.Open(target)
+	if err != nil {
+		return Profile{}, fmt.Errorf("cannot open profile file: %s", err)
+	}
+	defer profileFile.Close()
 
-			err = os.WriteFile(target, profileData, 0700)
-			if err != nil {
-				return Profile{}, fmt.Errorf("cannot write profile file: %s", err)
-			}
-		}
+	profileBytes, err := io.ReadAll(profileFile)
+	if err != nil {
+		return Profile{}, fmt.Errorf("cannot read profile file: %s", err)
+	}
 
-		// Validate the loaded profile
-		if err := validateProfile(migrated); err != nil {
-			return Profile{}, fmt.Errorf("invalid profile %s: %s", target, err)
-		}
+	migration, err := migrateProfileData(profileBytes)
+	if err != nil {
+		return Profile{}, fmt.Errorf("cannot migrate profile %s: %w", target, err)
+	}
+	migration.Path = target
+
+	values, err := resolveProfile(target, migration.Migrated, nil)
+	if err != nil {
+		return Profile{}, err
+	}
+	if writeMigrated {
+		warnUnknownKeys(target, profileBytes, Profile{})
+	}
+
+	profile, err := profileFromValues(values)
+	if err != nil {
+		return Profile{}, fmt.Errorf("cannot parse profile file: %s", err)
+	}
+	// Both describe the file rather than the profile.
+	profile.Extends = ""
+	profile.ProfileVersion = 0
+	profile.Model = catalog.Resolve(profile.Model)
+
+	// Validate the loaded profile
+	if err := ValidateProfile(profile, catalog); err != nil {
+		return Profile{}, fmt.Errorf("invalid profile %s: %s", target, err)
+	}
 
-		return migrated, nil
+	// If another process changed the file meanwhile, keep its version. The migrated profile is used either way.
+	if writeMigrated && isWithin(MustGetProfileDir(), target) {
+		if err := migration.Write(); err != nil && !errors.Is(err, util.ErrConflict) {
+			fmt.Fprintf(os.Stderr, "WARN: %v, the profile is migrated in memory only.\n", err)
+		}
 	}
 
-	return Profile{}, fmt.Errorf("profile file not found, tried: %s", strings.Join(toSearchPaths, ", "))
+	return profile, nil
+}
+
+// profileSearchPaths returns the paths to search for the profile in the project and global profile directories.
+func profileSearchPaths(cfg Config, overload string) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, dir := range ProfileDirs() {
+		for _, path := range createToSearchPaths(dir, cfg, overload) {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
 }
 
 func createToSearchPaths(profileDir string, cfg Config, overload string) []string {
@@ -133,6 +192,115 @@ func createToSearchPaths(profileDir string, cfg Config, overload string) []strin
 	return toSearchPaths
 }
 
+// ProfileFile is a profile file in one of the profile directories.
+type ProfileFile struct {
+	Name string
+	Path string
+}
+
+// ListProfiles returns the profiles in ProfileDirs sorted by name.
+// A project profile hides the global profile with the same name, as it is found first.
+func ListProfiles() ([]ProfileFile, error) {
+	if !hasDefaultProfile() {
+		if err := CreateInitialProfileFile(); err != nil {
+			return nil, fmt.Errorf("cannot create initial profile file: %s", err)
+		}
+	}
+	return listProfiles(ProfileDirs())
+}
+
+func listProfiles(dirs []string) ([]ProfileFile, error) {
+	var profiles []ProfileFile
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		files, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			name := file.Name()
+			// Hidden files are lock files and copies being edited.
+			if file.IsDir() || strings.HasPrefix(name, ".") || name == "config.yaml" || seen[name] || !(strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) {
+				continue
+			}
+			seen[name] = true
+			profiles = append(profiles, ProfileFile{Name: name, Path: filepath.Join(dir, name)})
+		}
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles, nil
+}
+
+// ProfileFileName returns the file name of the profile, adding .yaml unless it has an extension.
+func ProfileFileName(name string) string {
+	if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
+		return name
+	}
+	return name + ".yaml"
+}
+
+// FindProfileFile returns the profile with the name in ProfileDirs. Unlike FindProfile, paths are not accepted.
+func FindProfileFile(name string) (ProfileFile, error) {
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return ProfileFile{}, fmt.Errorf("invalid profile name %q, use the file name of a profile in %s", name, strings.Join(ProfileDirs(), " or "))
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		return ProfileFile{}, err
+	}
+	for _, candidate := range []string{name, ProfileFileName(name), name + ".yml"} {
+		for _, p := range profiles {
+			if p.Name == candidate {
+				return p, nil
+			}
+		}
+	}
+	return ProfileFile{}, fmt.Errorf("profile %s not found in %s", name, strings.Join(ProfileDirs(), ", "))
+}
+
+// NewProfilePath returns the path for a new profile with the name, in the project profile directory if project is set.
+// It returns an error if the name is invalid or a profile with the name exists.
+func NewProfilePath(name string, project bool) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/\\") || name == "config.yaml" {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+
+	dir := MustGetProfileDir()
+	if project {
+		projectDir := GetProjectDir()
+		if projectDir == "" {
+			return "", fmt.Errorf("no .aski directory found in the current directory or its parents")
+		}
+		dir = filepath.Join(projectDir, "profile")
+	}
+
+	path := filepath.Join(dir, ProfileFileName(name))
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("profile %s already exists", path)
+	}
+	return path, nil
+}
+
+// CreateProfileFile writes a new profile file, failing if it already exists.
+func CreateProfileFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	_, err := util.WriteFileChecked(path, data, 0600, util.Snapshot{})
+	if errors.Is(err, util.ErrConflict) {
+		return fmt.Errorf("profile %s already exists", path)
+	}
+	return err
+}
+
 func hasDefaultProfile() bool {
 	profileDir := MustGetProfileDir()
 	defaultProfilePath := filepath.Join(profileDir, "default.yaml")
@@ -156,8 +324,8 @@ func CreateInitialProfileFile() error {
 	}
 
 	profilePath := filepath.Join(profileDir, GetDefaultProfileFileName())
-	err = os.WriteFile(profilePath, profileData, 0700)
-	if err != nil {
+	_, err = util.WriteFileChecked(profilePath, profileData, 0600, util.Snapshot{})
+	if err != nil && !errors.Is(err, util.ErrConflict) {
 		return err
 	}
 
@@ -170,6 +338,7 @@ func InitialProfile() Profile {
 		currentUser.Username = "aski"
 	}
 	return Profile{
+		ProfileVersion: CurrentProfileVersion,
 		ProfileName:    "GPT4",
 		UserName:       currentUser.Username,
 		AutoSave:       true,
@@ -180,40 +349,41 @@ func InitialProfile() Profile {
 	}
 }
 
-func validateProfile(profile Profile) error {
+// ValidateProfile reports the first value of the profile that aski cannot use. The model is looked up in catalog.
+func ValidateProfile(profile Profile, catalog Catalog) error {
 	if profile.ProfileName == "" {
 		return fmt.Errorf("ProfileName must not be empty")
 	}
 	if len(profile.UserName) > 16 || !regexp.MustCompile("^[a-zA-Z0-9/\\\\]+$").MatchString(profile.UserName) {
-		return fmt.Errorf("UserName must be alphanumeric and no more than 8 characters")
+		return fmt.Errorf("UserName must be 1 to 16 letters, digits, / or \\, but got %q", profile.UserName)
 	}
 	if profile.SystemContext == "" {
 		return fmt.Errorf("SystemContext must not be empty")
 	}
 	if profile.Model == "" {
-		return fmt.Errorf("model must not be empty")
+		return fmt.Errorf("Model must not be empty")
 	}
 
-	if !strings.HasPrefix(profile.Model, "gpt") && !strings.HasPrefix(profile.Model, "claude") {
-		return fmt.Errorf("model must start with gpt or claude: %s", profile.Model)
+	if !catalog.Known(profile.Model) {
+		return fmt.Errorf("Model must start with gpt or claude, or be in Models of config.yaml, but got %q", profile.Model)
 	}
 
-	for _, message := range profile.Messages {
+	for i, message := range profile.Messages {
 		if message.Role == "" {
-			return fmt.Errorf("message Role must not be empty")
+			return fmt.Errorf("Messages[%d].Role must not be empty", i)
 		}
 		if message.Content == "" {
-			return fmt.Errorf("message Content must not be empty")
+			return fmt.Errorf("Messages[%d].Content must not be empty", i)
 		}
 	}
 
 	if profile.ResponseFormat != string(openai.ChatCompletionResponseFormatTypeJSONObject) &&
 		profile.ResponseFormat != string(openai.ChatCompletionResponseFormatTypeText) {
-		return fmt.Errorf("response_format must be either json_object or text")
+		return fmt.Errorf("ResponseFormat must be either json_object or text, but got %q", profile.ResponseFormat)
 	}
 
-	if !strings.HasPrefix(profile.Model, "gpt") && profile.ResponseFormat == string(openai.ChatCompletionResponseFormatTypeJSONObject) {
-		return fmt.Errorf("response_format must be text for non-GPT models")
+	if catalog.Provider(profile.Model) != ProviderOpenAI && profile.ResponseFormat == string(openai.ChatCompletionResponseFormatTypeJSONObject) {
+		return fmt.Errorf("ResponseFormat must be text for non-OpenAI models")
 	}
 
 	if profile.DiceRoll != "" {
@@ -227,18 +397,6 @@ func validateProfile(profile Profile) error {
 	return ValidateCustomParameters(profile.CustomParameters)
 }
 
-func migrateProfile(profile Profile) (Profile, bool) {
-	defaultProfile := InitialProfile()
-	changed := false
-
-	if profile.ResponseFormat == "" {
-		profile.ResponseFormat = defaultProfile.ResponseFormat
-		changed = true
-	}
-
-	return profile, changed
-}
-
 func ValidateCustomParameters(customParams CustomParameters) error {
 	if customParams.Temperature != 0 && (customParams.Temperature < 0 || customParams.Temperature > 2) {
 		return errors.New("temperature must be between 0 and 2")
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/kznrluk/aski/util"
+	"os"
+)
+
+// CurrentProfileVersion is the ProfileVersion written to new profiles.
+// When a change needs existing profiles to be rewritten, bump it and append a migration below.
+const CurrentProfileVersion = 1
+
+type profileMigration struct {
+	// version is the version the profile has after this migration.
+	version int
+	// description is shown by `aski profile migrate`.
+	description string
+	migrate     func(d *profileDocument) error
+}
+
+// profileMigrations are applied in order to profiles whose version is older than the migration's version.
+// Profiles written before ProfileVersion existed are version 0.
+var profileMigrations = []profileMigration{
+	{version: 1, description: "set ResponseFormat to text", migrate: addResponseFormat},
+}
+
+// addResponseFormat sets ResponseFormat, which profiles written before it existed lack.
+// A profile extending another gets it from its base.
+func addResponseFormat(d *profileDocument) error {
+	if d.has("Extends") {
+		return nil
+	}
+	var p Profile
+	if err := d.decode(&p); err != nil {
+		return err
+	}
+	if p.ResponseFormat != "" {
+		return nil
+	}
+	return d.set("ResponseFormat", InitialProfile().ResponseFormat)
+}
+
+// ProfileMigration is the change of a profile file by the migrations it has not had yet.
+type ProfileMigration struct {
+	Path        string
+	FromVersion int
+	// Applied describes the changes, in order. It is empty when the profile is up to date.
+	Applied  []string
+	Original []byte
+	Migrated []byte
+}
+
+// PlanProfileMigration reads the profile at path and migrates it in memory. The file is not changed, see Write.
+func PlanProfileMigration(path string) (ProfileMigration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ProfileMigration{}, fmt.Errorf("cannot read profile file: %s", err)
+	}
+	m, err := migrateProfileData(data)
+	if err != nil {
+		return ProfileMigration{}, fmt.Errorf("cannot migrate profile %s: %w", path, err)
+	}
+	m.Path = path
+	return m, nil
+}
+
+// BackupPath is where Write keeps the profile as it was before the migration.
+func (m ProfileMigration) BackupPath() string {
+	return fmt.Sprintf("%s.v%d.bak", m.Path, m.FromVersion)
+}
+
+// Write saves a copy of the profile to BackupPath and replaces the profile with the migrated one, keeping its mode.
+// If another process changed the profile since it was read, it is left as is and util.ErrConflict is returned.
+func (m ProfileMigration) Write() error {
+	if len(m.Applied) == 0 {
+		return nil
+	}
+
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(m.Path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	if err := util.WriteFileAtomic(m.BackupPath(), m.Original, perm); err != nil {
+		return fmt.Errorf("cannot back up profile file: %w", err)
+	}
+	if _, err := util.WriteFileChecked(m.Path, m.Migrated, perm, util.SnapshotOf(m.Original)); err != nil {
+		return fmt.Errorf("cannot write profile file: %w", err)
+	}
+	return nil
+}
+
+// migrateProfileData applies the migrations the profile has not had yet. Profiles that are up to date are returned as
+// they are, others are edited in place so that their comments are kept.
+func migrateProfileData(data []byte) (ProfileMigration, error) {
+	m := ProfileMigration{Original: data, Migrated: data}
+
+	var header struct {
+		ProfileVersion int `yaml:"ProfileVersion"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return m, err
+	}
+	m.FromVersion = header.ProfileVersion
+	if m.FromVersion > CurrentProfileVersion {
+		return m, fmt.Errorf("profile version %d is newer than the supported version %d, please update aski", m.FromVersion, CurrentProfileVersion)
+	}
+	if m.FromVersion == CurrentProfileVersion {
+		return m, nil
+	}
+
+	d, err := parseProfileDocument(data)
+	if err != nil {
+		return m, err
+	}
+	for _, migration := range profileMigrations {
+		if m.FromVersion >= migration.version {
+			continue
+		}
+		before := d.bytes()
+		if err := migration.migrate(d); err != nil {
+			return m, fmt.Errorf("cannot migrate profile to version %d: %w", migration.version, err)
+		}
+		if !bytes.Equal(before, d.bytes()) {
+			m.Applied = append(m.Applied, migration.description)
+		}
+	}
+	if err := d.set("ProfileVersion", CurrentProfileVersion); err != nil {
+		return m, err
+	}
+	m.Applied = append(m.Applied, fmt.Sprintf("set ProfileVersion to %d", CurrentProfileVersion))
+
+	m.Migrated = d.bytes()
+	return m, nil
+}
+
+// profileDocument is a profile file parsed with its comments, so migrations can change values
+// without reformatting the rest of the file.
+type profileDocument struct {
+	file *ast.File
+	body *ast.MappingNode
+}
+
+func parseProfileDocument(data []byte) (*profileDocument, error) {
+	file, err := parser.ParseBytes(data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Docs) != 1 {
+		return nil, errors.New("a profile must be a single YAML document")
+	}
+
+	d := &profileDocument{file: file}
+	switch body := file.Docs[0].Body.(type) {
+	case *ast.MappingNode:
+		d.body = body
+	case *ast.MappingValueNode:
+		// A file with a single key is parsed as the key alone.
+		d.body = ast.Mapping(body.GetToken(), false, body)
+		file.Docs[0].Body = d.body
+	default:
+		return nil, errors.New("a profile must be a mapping of keys to values")
+	}
+	return d, nil
+}
+
+func (d *profileDocument) find(key string) *ast.MappingValueNode {
+	for _, v := range d.body.Values {
+		if v.Key.String() == key {
+			return v
+		}
+	}
+	return nil
+}
+
+func (d *profileDocument) has(key string) bool {
+	return d.find(key) != nil
+}
+
+// decode unmarshals the document as it is now into v.
+func (d *profileDocument) decode(v interface{}) error {
+	return yaml.Unmarshal(d.bytes(), v)
+}
+
+// set replaces the value of the top level key, keeping its comment, or adds the key at the end.
+func (d *profileDocument) set(key string, value interface{}) error {
+	data, err := yaml.Marshal(map[string]interface{}{key: value})
+	if err != nil {
+		return err
+	}
+	snippet, err := parseProfileDocument(data)
+	if err != nil {
+		return err
+	}
+	node := snippet.body.Values[0]
+
+	if v := d.find(key); v != nil {
+		if comment := v.Value.GetComment(); comment != nil {
+			_ = node.Value.SetComment(comment)
+		}
+		v.Value = node.Value
+		return nil
+	}
+	d.body.Values = append(d.body.Values, node)
+	return nil
+}
+
+func (d *profileDocument) bytes() []byte {
+	s := d.file.String()
+	if len(s) == 0 || s[len(s)-1] != '\n' {
+		s += "\n"
+	}
+	return []byte(s)
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateProfileData(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+		applied  int
+	}{
+		{
+			name: "keeps comments",
+			data: "# My profile\nProfileName: Old # shown in the prompt\nModel: gpt-4\nUserName: me\nSystemContext: hi\n",
+			expected: "# My profile\nProfileName: Old # shown in the prompt\nModel: gpt-4\nUserName: me\nSystemContext: hi\n" +
+				"ResponseFormat: text\nProfileVersion: 1\n",
+			applied: 2,
+		},
+		{
+			name:     "replaces an empty value",
+			data:     "ProfileName: Old\nResponseFormat: \"\" # set later\n",
+			expected: "ProfileName: Old\nResponseFormat: text # set later\nProfileVersion: 1\n",
+			applied:  2,
+		},
+		{
+			name:     "single key",
+			data:     "ProfileName: Old\n",
+			expected: "ProfileName: Old\nResponseFormat: text\nProfileVersion: 1\n",
+			applied:  2,
+		},
+		{
+			name:     "base provides ResponseFormat",
+			data:     "Extends: base\nProfileName: Child\n",
+			expected: "Extends: base\nProfileName: Child\nProfileVersion: 1\n",
+			applied:  1,
+		},
+		{
+			name:     "up to date",
+			data:     "ProfileVersion: 1\nProfileName:   Untouched\n",
+			expected: "ProfileVersion: 1\nProfileName:   Untouched\n",
+			applied:  0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := migrateProfileData([]byte(tc.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(m.Migrated) != tc.expected {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expected, m.Migrated)
+			}
+			if len(m.Applied) != tc.applied {
+				t.Errorf("expected %d changes, got %v", tc.applied, m.Applied)
+			}
+		})
+	}
+
+	if _, err := migrateProfileData([]byte("ProfileVersion: 99\n")); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected an error for a newer version, got %v", err)
+	}
+}
+
+func TestLoadProfileMigrates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := MustGetProfileDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "old.yaml")
+	original := "# Kept\nProfileName: Old\nModel: gpt-4\nUserName: me\nSystemContext: hi\n"
+	if err := os.WriteFile(path, []byte(original), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadProfile(path, BuiltinCatalog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ResponseFormat != "text" || p.ProfileVersion != 0 {
+		t.Errorf("unexpected profile %+v", p)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# Kept\n") || !strings.Contains(string(data), "ProfileVersion: 1\n") {
+		t.Errorf("unexpected migrated file:\n%s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("expected the mode to be kept, got %v (%v)", info.Mode(), err)
+	}
+
+	backup, err := os.ReadFile(path + ".v0.bak")
+	if err != nil || string(backup) != original {
+		t.Errorf("expected a backup of the original, got %q (%v)", backup, err)
+	}
+}
+
+func TestLoadProfileMigratesProjectInMemory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := filepath.Join(t.TempDir(), ".aski", "profile")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "old.yaml")
+	original := "ProfileName: Old\nModel: gpt-4\nUserName: me\nSystemContext: hi\n"
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadProfile(path, BuiltinCatalog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ResponseFormat != "text" {
+		t.Errorf("expected the profile to be migrated, got %+v", p)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != original {
+		t.Errorf("expected the project profile to be left as is, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); !os.IsNotExist(err) {
+		t.Errorf("expected no backup, got %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -52,3 +54,42 @@ func TestCreateToSearchPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestListProfiles(t *testing.T) {
+	project := t.TempDir()
+	global := t.TempDir()
+	files := map[string][]string{
+		project: {"review.yaml", "shared.yaml", ".aski-edit-1.yaml", "notes.txt"},
+		global:  {"default.yaml", "shared.yaml", "old.yml", "config.yaml"},
+	}
+	for dir, names := range files {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("ProfileName: test\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	profiles, err := listProfiles([]string{project, global, filepath.Join(global, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ProfileFile{
+		{Name: "default.yaml", Path: filepath.Join(global, "default.yaml")},
+		{Name: "old.yml", Path: filepath.Join(global, "old.yml")},
+		{Name: "review.yaml", Path: filepath.Join(project, "review.yaml")},
+		{Name: "shared.yaml", Path: filepath.Join(project, "shared.yaml")},
+	}
+	if !reflect.DeepEqual(profiles, expected) {
+		t.Errorf("expected %v, got %v", expected, profiles)
+	}
+}
+
+func TestProfileFileName(t *testing.T) {
+	for name, expected := range map[string]string{"review": "review.yaml", "review.yaml": "review.yaml", "old.yml": "old.yml"} {
+		if got := ProfileFileName(name); got != expected {
+			t.Errorf("ProfileFileName(%q): expected %q, got %q", name, expected, got)
+		}
+	}
+}
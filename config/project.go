@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// projectDirName is the directory that holds the profiles and history of a project, like the global .aski directory.
+const projectDirName = ".aski"
+
+var (
+	projectDirOnce sync.Once
+	projectDir     string
+)
+
+// FindProjectDir walks up from start like git, and returns the first .aski directory that is not globalDir.
+func FindProjectDir(start string, globalDir string) (string, bool) {
+	globalInfo, _ := os.Stat(globalDir)
+
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	for {
+		candidate := filepath.Join(dir, projectDirName)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			if globalInfo == nil || !os.SameFile(info, globalInfo) {
+				return candidate, true
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// GetProjectDir returns the .aski directory of the project containing the working directory, or "" outside a project.
+func GetProjectDir() string {
+	projectDirOnce.Do(func() {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return
+		}
+		if dir, ok := FindProjectDir(cwd, MustGetAskiDir()); ok {
+			projectDir = dir
+		}
+	})
+	return projectDir
+}
+
+// projectSubDir returns the named directory in the project, or "" if it does not exist.
+func projectSubDir(name string) string {
+	dir := GetProjectDir()
+	if dir == "" {
+		return ""
+	}
+
+	path := filepath.Join(dir, name)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return ""
+	}
+	return path
+}
+
+// ProfileDirs returns the directories searched for profiles, the project profiles first.
+func ProfileDirs() []string {
+	if dir := projectSubDir("profile"); dir != "" {
+		return []string{dir, MustGetProfileDir()}
+	}
+	return []string{MustGetProfileDir()}
+}
+
+// HistoryDirs returns the directories searched for history files. The first one is where new conversations are saved.
+func HistoryDirs() []string {
+	if dir := projectSubDir("history"); dir != "" {
+		return []string{dir, MustGetGlobalHistoryDir()}
+	}
+	return []string{MustGetGlobalHistoryDir()}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindProjectDir(t *testing.T) {
+	root := t.TempDir()
+	global := filepath.Join(root, "home", ".aski")
+	project := filepath.Join(root, "home", "src", "repo", ".aski")
+	nested := filepath.Join(root, "home", "src", "repo", "pkg", "sub")
+	outside := filepath.Join(root, "home", "src", "other")
+	for _, dir := range []string{global, project, nested, outside} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		start    string
+		expected string
+	}{
+		{name: "Project root", start: filepath.Dir(project), expected: project},
+		{name: "Nested directory", start: nested, expected: project},
+		{name: "Global directory is not a project", start: outside, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, ok := FindProjectDir(tc.start, global)
+			if dir != tc.expected || ok != (tc.expected != "") {
+				t.Errorf("expected %q, got %q (%v)", tc.expected, dir, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retention limits the conversations kept in the history directory. See `aski history gc`.
+// Zero values are unlimited.
+type Retention struct {
+	// MaxAge is how long after their last message conversations are deleted, such as "90d", "12w" or "720h".
+	MaxAge string `yaml:"MaxAge,omitempty"`
+	// MaxCount is the number of newest conversations to keep.
+	MaxCount int `yaml:"MaxCount,omitempty"`
+	// MaxSize is the total size of the conversations to keep, such as "500KB" or "100MB".
+	MaxSize string `yaml:"MaxSize,omitempty"`
+	// Auto runs the garbage collection when aski starts.
+	Auto bool `yaml:"Auto,omitempty"`
+	// Profiles overrides the limits for the conversations of a profile, by ProfileName.
+	// Fields left empty fall back to the global limits.
+	Profiles map[string]Retention `yaml:"Profiles,omitempty"`
+}
+
+// sizeUnits are the suffixes accepted by MaxSize. Longer suffixes are listed first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{suffix: "GB", bytes: 1 << 30},
+	{suffix: "MB", bytes: 1 << 20},
+	{suffix: "KB", bytes: 1 << 10},
+	{suffix: "G", bytes: 1 << 30},
+	{suffix: "M", bytes: 1 << 20},
+	{suffix: "K", bytes: 1 << 10},
+	{suffix: "B", bytes: 1},
+}
+
+// ForProfile returns the limits for the conversations of the profile, and whether the profile overrides them.
+func (r Retention) ForProfile(profile string) (Retention, bool) {
+	for name, p := range r.Profiles {
+		if !strings.EqualFold(name, profile) {
+			continue
+		}
+
+		merged := Retention{MaxAge: r.MaxAge, MaxCount: r.MaxCount, MaxSize: r.MaxSize}
+		if p.MaxAge != "" {
+			merged.MaxAge = p.MaxAge
+		}
+		if p.MaxCount != 0 {
+			merged.MaxCount = p.MaxCount
+		}
+		if p.MaxSize != "" {
+			merged.MaxSize = p.MaxSize
+		}
+		return merged, true
+	}
+	return Retention{MaxAge: r.MaxAge, MaxCount: r.MaxCount, MaxSize: r.MaxSize}, false
+}
+
+// IsZero reports whether no limit is set, globally or for any profile.
+func (r Retention) IsZero() bool {
+	return r.MaxAge == "" && r.MaxCount == 0 && r.MaxSize == "" && len(r.Profiles) == 0
+}
+
+// Age parses MaxAge. Besides the units of time.ParseDuration, "d" for days and "w" for weeks are accepted.
+func (r Retention) Age() (time.Duration, error) {
+	s := strings.TrimSpace(r.MaxAge)
+	if s == "" {
+		return 0, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) {
+			if n < 0 {
+				return 0, fmt.Errorf("invalid MaxAge: %s", r.MaxAge)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid MaxAge, expected e.g. 90d, 12w or 720h: %s", r.MaxAge)
+	}
+	return d, nil
+}
+
+// Size parses MaxSize in bytes. The units are powers of 1024, and a number without a unit is bytes.
+func (r Retention) Size() (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(r.MaxSize))
+	if s == "" {
+		return 0, nil
+	}
+
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			unit = u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid MaxSize, expected e.g. 500KB or 100MB: %s", r.MaxSize)
+	}
+	return int64(n * float64(unit)), nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionAgeAndSize(t *testing.T) {
+	ages := map[string]time.Duration{
+		"":     0,
+		"90d":  90 * 24 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+		"720h": 720 * time.Hour,
+	}
+	for s, expected := range ages {
+		got, err := Retention{MaxAge: s}.Age()
+		if err != nil || got != expected {
+			t.Errorf("MaxAge %q: expected %v, got %v (%v)", s, expected, got, err)
+		}
+	}
+
+	sizes := map[string]int64{
+		"":       0,
+		"512":    512,
+		"500KB":  500 << 10,
+		"1.5 mb": 3 << 19,
+		"2G":     2 << 30,
+	}
+	for s, expected := range sizes {
+		got, err := Retention{MaxSize: s}.Size()
+		if err != nil || got != expected {
+			t.Errorf("MaxSize %q: expected %d, got %d (%v)", s, expected, got, err)
+		}
+	}
+
+	for _, r := range []Retention{{MaxAge: "-1d"}, {MaxAge: "soon"}, {MaxSize: "-1MB"}, {MaxSize: "big"}} {
+		if _, err := r.Age(); err == nil && r.MaxAge != "" {
+			t.Errorf("expected an error for MaxAge %q", r.MaxAge)
+		}
+		if _, err := r.Size(); err == nil && r.MaxSize != "" {
+			t.Errorf("expected an error for MaxSize %q", r.MaxSize)
+		}
+	}
+}
+
+func TestRetentionForProfile(t *testing.T) {
+	r := Retention{
+		MaxAge:   "90d",
+		MaxCount: 100,
+		Profiles: map[string]Retention{"Claude": {MaxCount: 10}},
+	}
+
+	got, ok := r.ForProfile("claude")
+	if !ok || got.MaxAge != "90d" || got.MaxCount != 10 {
+		t.Errorf("unexpected override: %+v, %v", got, ok)
+	}
+
+	got, ok = r.ForProfile("GPT4")
+	if ok || got.MaxCount != 100 || got.Profiles != nil {
+		t.Errorf("unexpected default: %+v, %v", got, ok)
+	}
+}
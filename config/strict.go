@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// KeyError is a key in a YAML file that does not match any field, such as a misspelled one.
+type KeyError struct {
+	// Path is the dotted path of the key, such as "CustomParameters.temprature".
+	Path   string
+	Line   int
+	Column int
+	// Suggestion is a known key with a similar name, if any.
+	Suggestion string
+}
+
+func (e KeyError) Error() string {
+	msg := fmt.Sprintf("line %d: unknown key %s", e.Line, e.Path)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %s?", e.Suggestion)
+	}
+	return msg
+}
+
+// warnedKeyFiles avoids repeating the warnings about unknown keys of a file, as the config is read many times.
+var warnedKeyFiles = map[string]bool{}
+
+// UnknownKeys returns the keys of the YAML document that do not match a field of v, a struct or a pointer to one.
+// Keys are matched like the decoder does: the name in the yaml tag, or else the lower case field name.
+// A list may be given with a "+" suffix, such as "Messages+", to append to the list of a base, see MergeLayers.
+func UnknownKeys(data []byte, v interface{}) ([]KeyError, error) {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []KeyError
+	for _, doc := range file.Docs {
+		if doc.Body != nil {
+			checkKeys(doc.Body, reflect.TypeOf(v), "", &errs)
+		}
+	}
+	return errs, nil
+}
+
+// warnUnknownKeys prints the unknown keys of the file at path once, without failing, as older versions of aski
+// ignored them. `aski config validate` reports them as errors.
+func warnUnknownKeys(path string, data []byte, v interface{}) {
+	if warnedKeyFiles[path] {
+		return
+	}
+	warnedKeyFiles[path] = true
+
+	errs, err := UnknownKeys(data, v)
+	if err != nil {
+		return
+	}
+	for _, e := range errs {
+		fmt.Fprintf(os.Stderr, "WARN: %s: %v\n", path, e)
+	}
+}
+
+func checkKeys(node ast.Node, t reflect.Type, prefix string, errs *[]KeyError) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch n := node.(type) {
+	case *ast.DocumentNode:
+		if n.Body != nil {
+			checkKeys(n.Body, t, prefix, errs)
+		}
+	case *ast.TagNode:
+		checkKeys(n.Value, t, prefix, errs)
+	case *ast.MappingNode:
+		for _, value := range n.Values {
+			checkKeys(value, t, prefix, errs)
+		}
+	case *ast.MappingValueNode:
+		checkMappingValue(n, t, prefix, errs)
+	case *ast.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, value := range n.Values {
+			checkKeys(value, t.Elem(), fmt.Sprintf("%s[%d]", strings.TrimSuffix(prefix, "."), i)+".", errs)
+		}
+	}
+}
+
+func checkMappingValue(n *ast.MappingValueNode, t reflect.Type, prefix string, errs *[]KeyError) {
+	key := n.Key.String()
+	if s, ok := n.Key.(*ast.StringNode); ok {
+		key = s.Value
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		checkKeys(n.Value, t.Elem(), prefix+key+".", errs)
+	case reflect.Struct:
+		fields := yamlFields(t)
+		name := key
+		field, ok := fields[name]
+		if !ok && strings.HasSuffix(name, "+") {
+			name = strings.TrimSuffix(name, "+")
+			field, ok = fields[name]
+			ok = ok && field.Kind() == reflect.Slice
+		}
+		if !ok {
+			token := n.Key.GetToken()
+			*errs = append(*errs, KeyError{
+				Path:       prefix + key,
+				Line:       token.Position.Line,
+				Column:     token.Position.Column,
+				Suggestion: suggestKey(key, fields),
+			})
+			return
+		}
+		checkKeys(n.Value, field, prefix+name+".", errs)
+	}
+}
+
+// yamlFields maps the keys of the struct to the types of their fields.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		if f.Anonymous || strings.Contains(tag, ",inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+
+		name := strings.ToLower(f.Name)
+		if options[0] != "" {
+			name = options[0]
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// suggestKey returns the known key closest to key, if it is close enough to be a typo.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+		if d := editDistance(strings.ToLower(name), strings.ToLower(key)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	data := `Extends: base.yaml
+ProfileName: Reviewer
+summarize: true
+Messages+:
+  - Role: user
+    Contnet: Review the following code.
+CustomParameters:
+  temprature: 0.2
+  logit_bias:
+    "1234": 10
+SystemContext+: not a list
+`
+	expected := []KeyError{
+		{Path: "summarize", Line: 3, Column: 1, Suggestion: "Summarize"},
+		{Path: "Messages[0].Contnet", Line: 6, Column: 5, Suggestion: "Content"},
+		{Path: "CustomParameters.temprature", Line: 8, Column: 3, Suggestion: "temperature"},
+		{Path: "SystemContext+", Line: 11, Column: 1, Suggestion: "SystemContext"},
+	}
+
+	errs, err := UnknownKeys([]byte(data), Profile{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, errs)
+	}
+
+	if errs[0].Error() != "line 3: unknown key summarize, did you mean Summarize?" {
+		t.Errorf("unexpected message: %s", errs[0].Error())
+	}
+}
+
+func TestUnknownKeysConfig(t *testing.T) {
+	data := `CurrentProfile: default.yaml
+HistoryRetention:
+  MaxAge: 90d
+  Profiles:
+    Claude:
+      MaxCount: 10
+      MaxCont: 5
+`
+	errs, err := UnknownKeys([]byte(data), Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []KeyError{{Path: "HistoryRetention.Profiles.Claude.MaxCont", Line: 7, Column: 7, Suggestion: "MaxCount"}}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, errs)
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateOptions are the inputs of profile templates.
+type TemplateOptions struct {
+	// Vars are the user variables, such as those from --var, available as {{.name}}.
+	Vars map[string]string
+	// AllowShell enables {{shell "command"}}, see Config.TemplateShell.
+	AllowShell bool
+	// Origin is the path of the profile file. Profiles outside the global profile directory, such as those of a project,
+	// are untrusted unless their project is in TrustedProjects: shell is disabled, file only reads files of the project
+	// and env only returns a few variables that are not secret.
+	Origin string
+	// TrustedProjects are project directories whose profiles are trusted like global ones, see Config.TrustedProjects.
+	TrustedProjects []string
+}
+
+// templateEnv are the variables env returns in untrusted profiles.
+var templateEnv = []string{"HOME", "LANG", "PWD", "SHELL", "TERM", "USER"}
+
+// RenderProfile renders SystemContext and the contents of Messages as text/template templates.
+// The functions are now, env, file, cwd and, if allowed, shell. Unknown variables are errors.
+func RenderProfile(p Profile, opts TemplateOptions) (Profile, error) {
+	system, err := renderTemplate("SystemContext", p.SystemContext, opts)
+	if err != nil {
+		return Profile{}, err
+	}
+	p.SystemContext = system
+
+	messages := make([]PreMessage, len(p.Messages))
+	for i, m := range p.Messages {
+		content, err := renderTemplate(fmt.Sprintf("Messages[%d]", i), m.Content, opts)
+		if err != nil {
+			return Profile{}, err
+		}
+		messages[i] = PreMessage{Role: m.Role, Content: content}
+	}
+	p.Messages = messages
+	return p, nil
+}
+
+// ParseVars parses variables given as name=value.
+func ParseVars(vars []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid variable, expected name=value: %s", v)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// CheckTemplates reports syntax errors in the templates of the profile without rendering them.
+func CheckTemplates(p Profile) error {
+	if _, err := parseTemplate("SystemContext", p.SystemContext, TemplateOptions{}); err != nil {
+		return err
+	}
+	for i, m := range p.Messages {
+		if _, err := parseTemplate(fmt.Sprintf("Messages[%d].Content", i), m.Content, TemplateOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderTemplate(name string, text string, opts TemplateOptions) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := parseTemplate(name, text, opts)
+	if err != nil {
+		return "", err
+	}
+
+	vars := opts.Vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("cannot render %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+func parseTemplate(name string, text string, opts TemplateOptions) (*template.Template, error) {
+	trusted := opts.trusted()
+	funcs := template.FuncMap{
+		"now": time.Now,
+		"env": func(name string) (string, error) {
+			if !trusted && !slices.Contains(templateEnv, name) {
+				return "", fmt.Errorf("env %s is not available in profiles of untrusted projects, %s", name, opts.trustHint())
+			}
+			return os.Getenv(name), nil
+		},
+		"cwd": os.Getwd,
+		"file": func(path string) (string, error) {
+			path = expandHome(path)
+			if !trusted {
+				root := opts.projectRoot()
+				if !isWithin(root, path) {
+					return "", fmt.Errorf("file can only read files in %s in profiles of untrusted projects, %s", root, opts.trustHint())
+				}
+			}
+			data, err := os.ReadFile(path)
+			return string(data), err
+		},
+		"shell": func(command string) (string, error) {
+			if !trusted {
+				return "", fmt.Errorf("shell is disabled in profiles of untrusted projects, %s", opts.trustHint())
+			}
+			if !opts.AllowShell {
+				return "", fmt.Errorf("shell is disabled, set TemplateShell: true in config.yaml to enable it")
+			}
+			out, err := runShell(command)
+			return strings.TrimRight(out, "\n"), err
+		},
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template in %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// trusted reports whether the profile is in the global profile directory or in one of TrustedProjects.
+func (o TemplateOptions) trusted() bool {
+	if o.Origin == "" {
+		return false
+	}
+	if isWithin(MustGetProfileDir(), o.Origin) {
+		return true
+	}
+	for _, dir := range o.TrustedProjects {
+		if isWithin(expandHome(dir), o.Origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// projectRoot returns the directory containing the .aski directory of the profile,
+// or the directory of the profile if it is not in a project.
+func (o TemplateOptions) projectRoot() string {
+	dir := filepath.Dir(o.Origin)
+	if projectDir, ok := FindProjectDir(dir, MustGetAskiDir()); ok {
+		return filepath.Dir(projectDir)
+	}
+	return dir
+}
+
+func (o TemplateOptions) trustHint() string {
+	return fmt.Sprintf("add %s to TrustedProjects in %s to allow it", o.projectRoot(), filepath.Join(MustGetAskiDir(), "config.yaml"))
+}
+
+// isWithin reports whether path is dir or in dir, after following symbolic links.
+func isWithin(dir string, path string) bool {
+	dir, err := realPath(dir)
+	if err != nil {
+		return false
+	}
+	path, err = realPath(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved, nil
+	}
+	return path, nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRenderProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	origin := filepath.Join(MustGetProfileDir(), "p.yaml")
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(notes, []byte("Use tabs."), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ASKI_TEST_TEAM", "platform")
+	cwd, _ := os.Getwd()
+
+	testCases := []struct {
+		name     string
+		text     string
+		opts     TemplateOptions
+		expected string
+		err      string
+	}{
+		{name: "Plain text", text: "You are helpful.", expected: "You are helpful."},
+		{name: "Variables", text: "Review {{.lang}} code.", opts: TemplateOptions{Vars: map[string]string{"lang": "Go"}}, expected: "Review Go code."},
+		{name: "Missing variable", text: "Review {{.lang}} code.", err: "lang"},
+		{name: "Environment", text: `Team {{env "ASKI_TEST_TEAM"}}`, expected: "Team platform"},
+		{name: "File", text: `Rules: {{file "` + filepath.ToSlash(notes) + `"}}`, expected: "Rules: Use tabs."},
+		{name: "Working directory", text: "In {{cwd}}", expected: "In " + cwd},
+		{name: "Date", text: `{{(now).Year}}`, expected: ""},
+		{name: "Shell is opt-in", text: `{{shell "echo main"}}`, err: "TemplateShell"},
+		{name: "Shell", text: `Branch {{shell "echo main"}}`, opts: TemplateOptions{AllowShell: true}, expected: "Branch main"},
+		{name: "Invalid template", text: "{{.lang", err: "invalid template"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && strings.Contains(tc.text, "shell") && tc.err == "" {
+				t.Skip("echo is a shell builtin")
+			}
+
+			tc.opts.Origin = origin
+			p, err := RenderProfile(Profile{SystemContext: tc.text, Messages: []PreMessage{{Role: "user", Content: tc.text}}}, tc.opts)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.expected != "" && (p.SystemContext != tc.expected || p.Messages[0].Content != tc.expected) {
+				t.Errorf("expected %q, got %q and %q", tc.expected, p.SystemContext, p.Messages[0].Content)
+			}
+			if strings.Contains(p.SystemContext, "{{") {
+				t.Errorf("template was not rendered: %q", p.SystemContext)
+			}
+		})
+	}
+}
+
+func TestRenderProfileUntrusted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ASKI_TEST_SECRET", "hunter2")
+	project := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(project, ".aski", "profile"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "notes.md"), []byte("Use tabs."), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("hunter2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	origin := filepath.Join(project, ".aski", "profile", "p.yaml")
+
+	testCases := []struct {
+		name     string
+		text     string
+		trusted  []string
+		expected string
+		err      string
+	}{
+		{name: "Shell", text: `{{shell "echo main"}}`, err: "TrustedProjects"},
+		{name: "Secret environment", text: `{{env "ASKI_TEST_SECRET"}}`, err: "not available"},
+		{name: "Harmless environment", text: `{{env "HOME"}}`, expected: os.Getenv("HOME")},
+		{name: "Project file", text: `{{file "` + filepath.ToSlash(filepath.Join(project, "notes.md")) + `"}}`, expected: "Use tabs."},
+		{name: "File outside the project", text: `{{file "` + filepath.ToSlash(outside) + `"}}`, err: "can only read files in"},
+		{name: "Trusted project", text: `{{env "ASKI_TEST_SECRET"}} {{file "` + filepath.ToSlash(outside) + `"}}`, trusted: []string{project}, expected: "hunter2 hunter2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := TemplateOptions{AllowShell: true, Origin: origin, TrustedProjects: tc.trusted}
+			p, err := RenderProfile(Profile{SystemContext: tc.text}, opts)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.SystemContext != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, p.SystemContext)
+			}
+		})
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"lang=Go", "query=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["lang"] != "Go" || vars["query"] != "a=b" {
+		t.Errorf("unexpected vars: %v", vars)
+	}
+
+	if _, err := ParseVars([]string{"novalue"}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
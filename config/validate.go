@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/goccy/go-yaml"
+	"os"
+	"sort"
+)
+
+// ValidateConfigFile checks that the config.yaml at path parses, has no unknown keys and that its values are usable.
+// The errors do not include the path, as `aski config validate` prints them under it.
+func ValidateConfigFile(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return []error{errors.New(yaml.FormatError(err, false, true))}
+	}
+
+	errs := KeyErrors(data, Config{})
+
+	names := []string{"HistoryRetention"}
+	retentions := map[string]Retention{"HistoryRetention": cfg.HistoryRetention}
+	for profile, r := range cfg.HistoryRetention.Profiles {
+		name := "HistoryRetention.Profiles." + profile
+		names = append(names, name)
+		retentions[name] = r
+	}
+	sort.Strings(names[1:])
+	for _, name := range names {
+		if _, err := retentions[name].Age(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		if _, err := retentions[name].Size(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	if t := cfg.Overrides.Temperature; t != nil {
+		if err := ValidateCustomParameters(CustomParameters{Temperature: *t}); err != nil {
+			errs = append(errs, fmt.Errorf("Overrides: %w", err))
+		}
+	}
+	if err := ValidateModels(cfg.Models); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// ValidateProfileFile checks that the profile at path has no unknown keys, and that the profile merged with the
+// profiles it extends is valid, including the syntax of its templates.
+func ValidateProfileFile(path string, catalog Catalog) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return []error{errors.New(yaml.FormatError(err, false, true))}
+	}
+
+	errs := KeyErrors(data, Profile{})
+
+	profile, err := ReadProfile(path, catalog)
+	if err != nil {
+		return append(errs, err)
+	}
+	if err := CheckTemplates(profile); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// KeyErrors returns the unknown keys of the YAML document as errors, see UnknownKeys.
+func KeyErrors(data []byte, v interface{}) []error {
+	keys, err := UnknownKeys(data, v)
+	if err != nil {
+		return []error{errors.New(yaml.FormatError(err, false, true))}
+	}
+
+	var errs []error
+	for _, k := range keys {
+		errs = append(errs, k)
+	}
+	return errs
+}
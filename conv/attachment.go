@@ -0,0 +1,123 @@
+package conv
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type (
+	// Attachment records a file whose contents were sent in a message.
+	Attachment struct {
+		Path    string
+		Hash    string
+		ModTime time.Time `yaml:",omitempty"`
+		// Contents is only set when the message does not contain the file as is, e.g. when it describes the changes.
+		Contents string `yaml:",omitempty"`
+	}
+
+	// AttachedFile is the latest state of an attached file on the path to HEAD.
+	AttachedFile struct {
+		Attachment Attachment
+		Message    Message
+		Contents   string
+	}
+)
+
+const (
+	attachmentPrefix = "Path: `%s`\n ```\n"
+	attachmentSuffix = "```"
+)
+
+// FormatAttachment returns the message content for a file.
+func FormatAttachment(path string, contents string) string {
+	return fmt.Sprintf(attachmentPrefix, path) + contents + attachmentSuffix
+}
+
+// parseAttachment extracts the path and the contents from a message created by FormatAttachment.
+func parseAttachment(content string) (string, string, bool) {
+	if !strings.HasPrefix(content, "Path: `") || !strings.HasSuffix(content, attachmentSuffix) {
+		return "", "", false
+	}
+
+	end := strings.Index(content, "`\n ```\n")
+	if end < 0 {
+		return "", "", false
+	}
+
+	path := content[len("Path: `"):end]
+	header := fmt.Sprintf(attachmentPrefix, path)
+	if len(content) < len(header)+len(attachmentSuffix) {
+		return "", "", false
+	}
+
+	return path, content[len(header) : len(content)-len(attachmentSuffix)], true
+}
+
+// ReplaceAttachmentContents returns the content of a message created by FormatAttachment with the file contents
+// replaced, keeping the path as it was shown. It returns false if the content is not such a message.
+func ReplaceAttachmentContents(content string, contents string) (string, bool) {
+	path, _, ok := parseAttachment(content)
+	if !ok {
+		return "", false
+	}
+	return FormatAttachment(path, contents), true
+}
+
+// AttachmentPath returns the path recorded for an attached file, which is absolute so that
+// a conversation restored from another directory refreshes the same files.
+func AttachmentPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+func HashContents(contents string) string {
+	return CalculateSHA1([]string{contents})
+}
+
+// AppendAttachment appends a user message with the file contents to HEAD.
+// The message shows the path as given, the attachment records it as AttachmentPath.
+func (c *conv) AppendAttachment(path string, contents string, modTime time.Time) Message {
+	return c.AppendAttachments(FormatAttachment(path, contents), []Attachment{{
+		Path:    AttachmentPath(path),
+		Hash:    HashContents(contents),
+		ModTime: modTime,
+	}})
+}
+
+// AppendAttachments appends a user message about the files to HEAD.
+func (c *conv) AppendAttachments(content string, attachments []Attachment) Message {
+	sha := CalculateSHA1([]string{ChatRoleUser, content, c.headSha1()})
+
+	return c.attach(Message{
+		Role:        ChatRoleUser,
+		Content:     content,
+		UserName:    c.Profile.UserName,
+		Attachments: attachments,
+	}, sha)
+}
+
+// AttachedFilesFromHead returns the latest state of every file attached on the path to HEAD, keyed by AttachmentPath.
+func (c conv) AttachedFilesFromHead() map[string]AttachedFile {
+	files := map[string]AttachedFile{}
+	for _, m := range c.MessagesFromHead() {
+		for _, a := range m.Attachments {
+			contents := a.Contents
+			if contents == "" {
+				if _, parsed, ok := parseAttachment(m.Content); ok {
+					contents = parsed
+				}
+			}
+
+			files[AttachmentPath(a.Path)] = AttachedFile{
+				Attachment: a,
+				Message:    m,
+				Contents:   contents,
+			}
+		}
+	}
+	return files
+}
@@ -5,12 +5,14 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
 	"github.com/kznrluk/aski/config"
 	"github.com/kznrluk/aski/session"
 	"github.com/kznrluk/aski/util"
 	"github.com/kznrluk/go-anthropic"
 	"github.com/sashabaranov/go-openai"
 	"strings"
+	"time"
 )
 
 type (
@@ -22,19 +24,50 @@ type (
 		Append(role string, message string) Message
 		SetSystem(message string)
 		GetSystem() string
+		SystemFromHead() string
+		RequestFromHead() (string, []Message)
+		AppendAttachment(path string, contents string, modTime time.Time) Message
+		AppendAttachments(content string, attachments []Attachment) Message
+		AttachedFilesFromHead() map[string]AttachedFile
+		Compact(summary string, firstKeptSha1 string) (Message, error)
 		SetProfile(profile config.Profile) error
 		Modify(m Message) error
 		ToOpenAIMessage() []openai.ChatCompletionMessage
-		ToAnthropicMessage() []anthropic.Message
+		ToAnthropicMessage() (string, []anthropic.Message)
 		ChangeHead(sha string) (Message, error)
+		Copy(m Message) Message
+		CherryPick(sha1Partial string) ([]Message, error)
+		Diverge(aSha1Partial string, bSha1Partial string) (Divergence, error)
+		PathTo(sha1Partial string) ([]Message, error)
+		Rebase(branchSha1Partial string, ontoSha1Partial string) ([]Message, error)
+		SetBranch(name string, sha1Partial string) (Message, error)
+		GetBranches() map[string]string
+		Subtree(sha1Partial string) ([]Message, error)
+		Unreachable() []Message
+		Remove(messages []Message)
 		GetProfile() config.Profile
+		GetTitle() string
+		SetTitle(title string)
+		GetKeep() bool
+		SetKeep(keep bool)
+		GetTags() []string
+		SetTags(tags []string)
 		ToYAML() ([]byte, error)
 	}
 
 	conv struct {
+		// Version is the schema version of the history file. See migration.go.
+		Version int `yaml:"version"`
+		// Title is a short description of the conversation, set with :title or generated after the first answer.
+		Title string `yaml:",omitempty"`
+		// Keep and Tags exempt the conversation from `aski history gc`.
+		Keep     bool     `yaml:",omitempty"`
+		Tags     []string `yaml:",omitempty"`
 		Profile  config.Profile
 		System   string
 		Messages []Message
+		// Branches maps branch names to the sha1 of their tip.
+		Branches map[string]string `yaml:",omitempty"`
 	}
 
 	Message struct {
@@ -44,12 +77,27 @@ type (
 		Content    string `yaml:"content,literal"`
 		UserName   string
 		Head       bool
+		CreatedAt  time.Time `yaml:",omitempty"`
+		// Attachments are the files whose contents are included in Content.
+		Attachments []Attachment `yaml:",omitempty"`
+	}
+
+	// Divergence describes two paths in the conversation tree that share a common ancestor.
+	// Ancestor is "ROOT" when the paths have nothing in common.
+	Divergence struct {
+		Ancestor string
+		A        []Message
+		B        []Message
 	}
 )
 
 const (
 	ChatRoleUser      = "user"
 	ChatRoleAssistant = "assistant"
+	// ChatRoleSystem messages change the system prompt for their descendants.
+	ChatRoleSystem = "system"
+	// ChatRoleSummary messages replace the messages before them in requests. See Compact.
+	ChatRoleSummary = "summary"
 )
 
 func (c conv) GetMessages() []Message {
@@ -67,10 +115,83 @@ func (c *conv) SetSystem(text string) {
 	c.System = text
 }
 
+// GetSystem returns the system prompt at ROOT.
 func (c conv) GetSystem() string {
 	return c.System
 }
 
+// SystemFromHead returns the system prompt in effect at HEAD, which is the nearest system message
+// on the path from ROOT, or the system prompt at ROOT if there is none.
+func (c conv) SystemFromHead() string {
+	messages := c.MessagesFromHead()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == ChatRoleSystem {
+			return messages[i].Content
+		}
+	}
+	return c.System
+}
+
+// RequestFromHead returns the system prompt and the messages to send for HEAD.
+// Messages before the last summary on the path are left out, and the summary is appended to the system prompt instead.
+func (c conv) RequestFromHead() (string, []Message) {
+	system := c.System
+	summary := ""
+	messages := []Message{}
+	for _, m := range c.MessagesFromHead() {
+		switch m.Role {
+		case ChatRoleSystem:
+			system = m.Content
+		case ChatRoleSummary:
+			summary = m.Content
+			messages = []Message{}
+		default:
+			messages = append(messages, m)
+		}
+	}
+
+	if summary != "" {
+		system = fmt.Sprintf("%s\n\nSummary of the earlier conversation:\n%s", system, summary)
+	}
+
+	return system, messages
+}
+
+// Compact records summary in place of the messages on the HEAD path before firstKeptSha1.
+// The summary is attached to the parent of the first kept message, and the kept messages up to HEAD are copied
+// under it, so the original messages stay in the tree. An empty firstKeptSha1 summarizes everything up to HEAD.
+func (c *conv) Compact(summary string, firstKeptSha1 string) (Message, error) {
+	path := c.MessagesFromHead()
+
+	var kept []Message
+	if firstKeptSha1 != "" {
+		for i, m := range path {
+			if m.Sha1 == firstKeptSha1 {
+				kept = path[i:]
+				break
+			}
+		}
+		if kept == nil {
+			return Message{}, fmt.Errorf("message %.6s is not on the path to HEAD", firstKeptSha1)
+		}
+
+		if _, err := c.ChangeHead(kept[0].ParentSha1); err != nil {
+			return Message{}, err
+		}
+	}
+
+	msg := c.attach(Message{
+		Role:    ChatRoleSummary,
+		Content: summary,
+	}, CalculateSHA1([]string{ChatRoleSummary, summary, c.headSha1()}))
+
+	for _, m := range kept {
+		c.Copy(m)
+	}
+
+	return msg, nil
+}
+
 func (c *conv) Modify(m Message) error {
 	for i, message := range c.Messages {
 		if message.Sha1 == m.Sha1 {
@@ -83,17 +204,9 @@ func (c *conv) Modify(m Message) error {
 }
 
 func (c *conv) Append(role string, message string) Message {
-	parent := "ROOT"
-	for i, m := range c.Messages {
-		if m.Head {
-			parent = m.Sha1
-		}
-		c.Messages[i].Head = false
-	}
+	sha := CalculateSHA1([]string{role, message, c.headSha1()})
 
-	sha := CalculateSHA1([]string{role, message, parent})
-
-	if c.Profile.DiceRoll != "" {
+	if c.Profile.DiceRoll != "" && (role == ChatRoleUser || role == ChatRoleAssistant) {
 		result, err := util.RollDice(c.Profile.DiceRoll)
 		if err != nil {
 			panic(err) // profile validation should have caught this
@@ -102,20 +215,115 @@ func (c *conv) Append(role string, message string) Message {
 	}
 
 	msg := Message{
-		Sha1:       sha,
-		ParentSha1: parent,
-		Role:       role,
-		Content:    message,
-		Head:       true,
+		Role:    role,
+		Content: message,
 	}
 
 	if role == ChatRoleUser {
 		msg.UserName = c.Profile.UserName
 	}
 
-	c.Messages = append(c.Messages, msg)
+	return c.attach(msg, sha)
+}
 
-	return msg
+// Copy appends a copy of m to HEAD. The content is kept as is, so dice are not rolled again.
+func (c *conv) Copy(m Message) Message {
+	sha := CalculateSHA1([]string{m.Role, m.Content, c.headSha1()})
+	return c.attach(Message{
+		Role:        m.Role,
+		Content:     m.Content,
+		UserName:    m.UserName,
+		Attachments: m.Attachments,
+	}, sha)
+}
+
+// CherryPick copies a user/assistant pair onto HEAD.
+// Either side of the pair can be specified; the other side is looked up from the tree.
+func (c *conv) CherryPick(sha1Partial string) ([]Message, error) {
+	msg, err := c.GetMessageFromSha1(sha1Partial)
+	if err != nil {
+		return nil, err
+	}
+
+	var pair []Message
+	switch msg.Role {
+	case ChatRoleUser:
+		pair = append(pair, msg)
+		for _, m := range c.Messages {
+			if m.ParentSha1 == msg.Sha1 && m.Role == ChatRoleAssistant {
+				pair = append(pair, m)
+				break
+			}
+		}
+	case ChatRoleAssistant:
+		parent, err := c.GetMessageFromSha1(msg.ParentSha1)
+		if err == nil && parent.Role == ChatRoleUser {
+			pair = append(pair, parent)
+		}
+		pair = append(pair, msg)
+	default:
+		return nil, fmt.Errorf("cannot cherry-pick %s message", msg.Role)
+	}
+
+	var copied []Message
+	for _, m := range pair {
+		copied = append(copied, c.Copy(m))
+	}
+
+	return copied, nil
+}
+
+// Diverge finds the common ancestor of two messages and the messages on each path after it.
+func (c conv) Diverge(aSha1Partial string, bSha1Partial string) (Divergence, error) {
+	a, err := c.PathTo(aSha1Partial)
+	if err != nil {
+		return Divergence{}, err
+	}
+
+	b, err := c.PathTo(bSha1Partial)
+	if err != nil {
+		return Divergence{}, err
+	}
+
+	common := 0
+	for common < len(a) && common < len(b) && a[common].Sha1 == b[common].Sha1 {
+		common++
+	}
+
+	ancestor := "ROOT"
+	if common > 0 {
+		ancestor = a[common-1].Sha1
+	}
+
+	return Divergence{
+		Ancestor: ancestor,
+		A:        a[common:],
+		B:        b[common:],
+	}, nil
+}
+
+// Rebase replays the messages of branch that are not part of onto's path on top of onto.
+// Like git, new Sha1s are calculated and the original messages are left in place. HEAD moves to the new tip.
+func (c *conv) Rebase(branchSha1Partial string, ontoSha1Partial string) ([]Message, error) {
+	d, err := c.Diverge(branchSha1Partial, ontoSha1Partial)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.A) == 0 {
+		return nil, fmt.Errorf("nothing to rebase: %s is already on the path of %s", branchSha1Partial, ontoSha1Partial)
+	}
+
+	if _, err := c.ChangeHead(ontoSha1Partial); err != nil {
+		return nil, err
+	}
+
+	var copied []Message
+	for _, m := range d.A {
+		copied = append(copied, c.Copy(m))
+	}
+
+	return copied, nil
 }
 
 func (c *conv) GetMessageFromSha1(sha1partial string) (Message, error) {
@@ -155,49 +363,253 @@ func (c *conv) ChangeHead(sha1Partial string) (Message, error) {
 	return Message{}, fmt.Errorf("no message found with provided sha1Partial: %s", sha1Partial)
 }
 
-func (c conv) MessagesFromHead() []Message {
-	foundHead := false
-	currentHead := ""
-	for !foundHead {
-		for _, message := range c.Messages {
-			if message.Head {
-				foundHead = true
-				currentHead = message.Sha1
-				break
+// SetBranch names the message as a branch tip. Named branches are kept by Unreachable.
+func (c *conv) SetBranch(name string, sha1Partial string) (Message, error) {
+	msg, err := c.GetMessageFromSha1(sha1Partial)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if c.Branches == nil {
+		c.Branches = map[string]string{}
+	}
+	c.Branches[name] = msg.Sha1
+
+	return msg, nil
+}
+
+func (c conv) GetBranches() map[string]string {
+	return c.Branches
+}
+
+// Subtree returns the message and all of its descendants.
+func (c conv) Subtree(sha1Partial string) ([]Message, error) {
+	msg, err := c.GetMessageFromSha1(sha1Partial)
+	if err != nil {
+		return nil, err
+	}
+
+	inTree := map[string]bool{msg.Sha1: true}
+	result := []Message{msg}
+	for changed := true; changed; {
+		changed = false
+		for _, m := range c.Messages {
+			if !inTree[m.Sha1] && inTree[m.ParentSha1] {
+				inTree[m.Sha1] = true
+				result = append(result, m)
+				changed = true
 			}
 		}
+	}
 
-		if !foundHead {
-			break
+	return result, nil
+}
+
+// Unreachable returns the messages that are neither on the path to HEAD nor on the path to a named branch.
+func (c conv) Unreachable() []Message {
+	reachable := map[string]bool{}
+	tips := []string{c.headSha1()}
+	for _, sha := range c.Branches {
+		tips = append(tips, sha)
+	}
+
+	for _, tip := range tips {
+		if tip == "ROOT" {
+			continue
 		}
+		for _, m := range c.chain(tip) {
+			reachable[m.Sha1] = true
+		}
+	}
 
-		messageChain := []Message{}
-		for currentHead != "" {
-			for i, message := range c.Messages {
-				if message.Sha1 == currentHead {
-					currentHead = message.ParentSha1
-					messageChain = append(messageChain, message)
-					break
-				} else if i == len(c.Messages)-1 {
-					currentHead = ""
-				}
+	var result []Message
+	for _, m := range c.Messages {
+		if !reachable[m.Sha1] {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
+
+// Remove deletes the messages from the tree. Children of removed messages are re-parented to the
+// nearest surviving ancestor, and HEAD and named branches pointing at removed messages move there as well.
+// As a sha1 is derived from the parent, the re-parented messages and their descendants get new ones, see rehash.
+func (c *conv) Remove(messages []Message) {
+	removed := map[string]bool{}
+	for _, m := range messages {
+		removed[m.Sha1] = true
+	}
+
+	parents := map[string]string{}
+	for _, m := range c.Messages {
+		parents[m.Sha1] = m.ParentSha1
+	}
+
+	survivor := func(sha string) string {
+		for removed[sha] {
+			sha = parents[sha]
+		}
+		return sha
+	}
+
+	newHead := ""
+	if head := c.headSha1(); removed[head] {
+		newHead = survivor(head)
+	}
+
+	for name, sha := range c.Branches {
+		if !removed[sha] {
+			continue
+		}
+		if tip := survivor(sha); tip != "ROOT" {
+			c.Branches[name] = tip
+		} else {
+			delete(c.Branches, name)
+		}
+	}
+
+	kept := []Message{}
+	moved := map[string]bool{}
+	for _, m := range c.Messages {
+		if removed[m.Sha1] {
+			continue
+		}
+		if removed[m.ParentSha1] {
+			m.ParentSha1 = survivor(m.ParentSha1)
+			moved[m.Sha1] = true
+		}
+		if newHead != "" {
+			m.Head = m.Sha1 == newHead
+		}
+		kept = append(kept, m)
+	}
+	c.Messages = kept
+	c.rehash(moved)
+}
+
+// rehash gives the moved messages and their descendants the sha1 of their role, content and new parent,
+// so the sha1s keep matching the tree and later messages cannot collide with them. Named branches follow.
+func (c *conv) rehash(moved map[string]bool) {
+	children := map[string][]int{}
+	existing := map[string]bool{}
+	var queue []int
+	for i, m := range c.Messages {
+		children[m.ParentSha1] = append(children[m.ParentSha1], i)
+		existing[m.Sha1] = true
+		if moved[m.Sha1] {
+			queue = append(queue, i)
+		}
+	}
+
+	renamed := map[string]string{}
+	for len(queue) > 0 {
+		m := &c.Messages[queue[0]]
+		queue = queue[1:]
+
+		old := m.Sha1
+		if parent, ok := renamed[m.ParentSha1]; ok {
+			m.ParentSha1 = parent
+		}
+		sha := CalculateSHA1([]string{m.Role, m.Content, m.ParentSha1})
+		if existing[sha] {
+			// An identical message is already there, keep them apart like the importer does.
+			sha = CalculateSHA1([]string{m.Role, m.Content, m.ParentSha1, old})
+		}
+		existing[sha] = true
+		renamed[old] = sha
+		m.Sha1 = sha
+		queue = append(queue, children[old]...)
+	}
+
+	for name, sha := range c.Branches {
+		if n, ok := renamed[sha]; ok {
+			c.Branches[name] = n
+		}
+	}
+}
+
+func (c conv) MessagesFromHead() []Message {
+	head := c.headSha1()
+	if head == "ROOT" {
+		return []Message{}
+	}
+
+	return c.chain(head)
+}
+
+// chain returns the messages from ROOT to the message with the given full sha1.
+func (c conv) chain(sha1 string) []Message {
+	messageChain := []Message{}
+	current := sha1
+	for current != "" && len(c.Messages) > 0 {
+		for i, message := range c.Messages {
+			if message.Sha1 == current {
+				current = message.ParentSha1
+				messageChain = append(messageChain, message)
+				break
+			} else if i == len(c.Messages)-1 {
+				current = ""
 			}
 		}
+	}
+
+	for i, j := 0, len(messageChain)-1; i < j; i, j = i+1, j-1 {
+		messageChain[i], messageChain[j] = messageChain[j], messageChain[i]
+	}
+
+	return messageChain
+}
+
+// PathTo returns the messages from ROOT to the message. It accepts a partial sha1 or ROOT.
+func (c conv) PathTo(sha1Partial string) ([]Message, error) {
+	if sha1Partial == "ROOT" {
+		return []Message{}, nil
+	}
 
-		for i, j := 0, len(messageChain)-1; i < j; i, j = i+1, j-1 {
-			messageChain[i], messageChain[j] = messageChain[j], messageChain[i]
+	msg, err := c.GetMessageFromSha1(sha1Partial)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.chain(msg.Sha1), nil
+}
+
+func (c conv) headSha1() string {
+	for _, m := range c.Messages {
+		if m.Head {
+			return m.Sha1
 		}
+	}
+	return "ROOT"
+}
+
+// attach appends msg as a child of HEAD with the given sha1 and moves HEAD to it.
+func (c *conv) attach(msg Message, sha string) Message {
+	msg.ParentSha1 = c.headSha1()
+	msg.Sha1 = sha
+	msg.Head = true
+	msg.CreatedAt = time.Now()
 
-		return messageChain
+	for i := range c.Messages {
+		c.Messages[i].Head = false
 	}
 
-	return []Message{}
+	c.Messages = append(c.Messages, msg)
+
+	return msg
 }
 
 func (c conv) ToOpenAIMessage() []openai.ChatCompletionMessage {
-	var chatMessages []openai.ChatCompletionMessage
+	system, messages := c.RequestFromHead()
+	chatMessages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: system,
+		},
+	}
 
-	for _, message := range c.MessagesFromHead() {
+	for _, message := range messages {
 		chatMessages = append(chatMessages, openai.ChatCompletionMessage{
 			Role:    message.Role,
 			Content: message.Content,
@@ -213,11 +625,12 @@ func (c conv) ToOpenAIMessage() []openai.ChatCompletionMessage {
 	return chatMessages
 }
 
-func (c conv) ToAnthropicMessage() []anthropic.Message {
+// ToAnthropicMessage returns the system prompt and the messages, as Anthropic does not include system messages in the conversation.
+func (c conv) ToAnthropicMessage() (string, []anthropic.Message) {
 	var chatMessages []anthropic.Message
 
-	// NOTE: Anthropic does not include system messages in the conversation
-	for _, message := range c.MessagesFromHead() {
+	system, messages := c.RequestFromHead()
+	for _, message := range messages {
 		var role string
 
 		if message.Role == ChatRoleUser {
@@ -234,27 +647,94 @@ func (c conv) ToAnthropicMessage() []anthropic.Message {
 	}
 
 	if session.Verbose() {
+		fmt.Printf("[system]: %.32s\n", system)
 		for _, message := range chatMessages {
 			fmt.Printf("[%s]: %.32s\n", message.Role, message.Content)
 		}
 	}
 
-	return chatMessages
+	return system, chatMessages
 }
 
 func (c conv) ToYAML() ([]byte, error) {
-	yamlBytes, err := yaml.Marshal(c)
+	node, err := yaml.ValueToNode(c)
 	if err != nil {
 		return nil, err
 	}
 
-	return yamlBytes, nil
+	ast.Walk(rawTabWriter{}, node)
+
+	return []byte(node.String() + "\n"), nil
+}
+
+// rawTabWriter replaces the \t escapes in double-quoted strings with raw tabs.
+// The YAML decoder does not unescape \t, so an escaped tab would be read back as a backslash and a "t".
+// This is why history files before version 1 needed decodeTabEscapes.
+type rawTabWriter struct{}
+
+func (w rawTabWriter) Visit(node ast.Node) ast.Visitor {
+	s, ok := node.(*ast.StringNode)
+	if !ok || !strings.HasPrefix(s.Value, `"`) || !strings.Contains(s.Value, `\t`) {
+		return w
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s.Value); i++ {
+		if s.Value[i] == '\\' && i+1 < len(s.Value) {
+			if s.Value[i+1] == 't' {
+				sb.WriteByte('\t')
+			} else {
+				sb.WriteString(s.Value[i : i+2])
+			}
+			i++
+			continue
+		}
+		sb.WriteByte(s.Value[i])
+	}
+
+	s.Value = sb.String()
+	s.Token.Value = s.Value
+	return w
 }
 
 func (c conv) GetProfile() config.Profile {
 	return c.Profile
 }
 
+func (c conv) GetTitle() string {
+	return c.Title
+}
+
+func (c *conv) SetTitle(title string) {
+	c.Title = strings.TrimSpace(title)
+}
+
+func (c conv) GetKeep() bool {
+	return c.Keep
+}
+
+func (c *conv) SetKeep(keep bool) {
+	c.Keep = keep
+}
+
+func (c conv) GetTags() []string {
+	return c.Tags
+}
+
+// SetTags replaces the tags, dropping empty and duplicate ones.
+func (c *conv) SetTags(tags []string) {
+	c.Tags = nil
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		c.Tags = append(c.Tags, t)
+	}
+}
+
 func (c *conv) SetProfile(profile config.Profile) error {
 	c.Profile = profile
 	return nil
@@ -264,7 +744,7 @@ func (c conv) convertSystemToMessage() Message {
 	return Message{
 		Sha1:       CalculateSHA1([]string{c.System}),
 		ParentSha1: "ROOT",
-		Role:       "system",
+		Role:       ChatRoleSystem,
 		Content:    c.System,
 		Head:       false,
 	}
@@ -272,11 +752,23 @@ func (c conv) convertSystemToMessage() Message {
 
 func NewConversation(profile config.Profile) Conversation {
 	return &conv{
+		Version:  CurrentVersion,
 		Profile:  profile,
 		Messages: []Message{},
 	}
 }
 
+// FromMessages creates a conversation from an already built tree, e.g. one converted from another client.
+// Messages are linked by ParentSha1 and exactly one of them should be marked as Head.
+func FromMessages(profile config.Profile, system string, messages []Message) Conversation {
+	return &conv{
+		Version:  CurrentVersion,
+		Profile:  profile,
+		System:   system,
+		Messages: messages,
+	}
+}
+
 func FromYAML(yamlBytes []byte) (Conversation, error) {
 	var c conv
 	err := yaml.Unmarshal(yamlBytes, &c)
@@ -284,9 +776,8 @@ func FromYAML(yamlBytes []byte) (Conversation, error) {
 		return nil, err
 	}
 
-	// Decode tab escape sequences
-	for i, message := range c.Messages {
-		c.Messages[i].Content = strings.ReplaceAll(message.Content, "\\t", "\t")
+	if err := migrate(&c); err != nil {
+		return nil, err
 	}
 
 	return &c, nil
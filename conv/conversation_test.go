@@ -0,0 +1,280 @@
+package conv
+
+import (
+	"github.com/kznrluk/aski/config"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestConversation() *conv {
+	return &conv{
+		Profile:  config.Profile{UserName: "tester"},
+		Messages: []Message{},
+	}
+}
+
+func contents(messages []Message) []string {
+	var result []string
+	for _, m := range messages {
+		result = append(result, m.Content)
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCherryPick(t *testing.T) {
+	c := newTestConversation()
+	q1 := c.Append(ChatRoleUser, "q1")
+	c.Append(ChatRoleAssistant, "a1")
+	branch := c.Append(ChatRoleUser, "q2")
+	a2 := c.Append(ChatRoleAssistant, "a2")
+
+	_, _ = c.ChangeHead(q1.Sha1)
+	c.Append(ChatRoleAssistant, "a1'")
+
+	testCases := []struct {
+		name  string
+		sha1  string
+		roles []string
+	}{
+		{name: "From user message", sha1: branch.Sha1[:6], roles: []string{ChatRoleUser, ChatRoleAssistant}},
+		{name: "From assistant message", sha1: a2.Sha1[:6], roles: []string{ChatRoleUser, ChatRoleAssistant}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			head := c.Last()
+			copied, err := c.CherryPick(tc.sha1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(copied) != len(tc.roles) {
+				t.Fatalf("Expected %d messages, but got %d", len(tc.roles), len(copied))
+			}
+			if copied[0].ParentSha1 != head.Sha1 {
+				t.Errorf("Expected parent %s, but got %s", head.Sha1, copied[0].ParentSha1)
+			}
+			if copied[1].ParentSha1 != copied[0].Sha1 || !copied[1].Head {
+				t.Errorf("Expected the assistant message to be the new HEAD")
+			}
+			for i, role := range tc.roles {
+				if copied[i].Role != role {
+					t.Errorf("Expected role %s, but got %s", role, copied[i].Role)
+				}
+			}
+			if !equalStrings(contents(copied), []string{"q2", "a2"}) {
+				t.Errorf("Unexpected contents %v", contents(copied))
+			}
+		})
+	}
+}
+
+func TestRebase(t *testing.T) {
+	c := newTestConversation()
+	q1 := c.Append(ChatRoleUser, "q1")
+	c.Append(ChatRoleAssistant, "a1")
+	c.Append(ChatRoleUser, "q2")
+	tip := c.Append(ChatRoleAssistant, "a2")
+
+	_, _ = c.ChangeHead(q1.Sha1)
+	onto := c.Append(ChatRoleAssistant, "a1'")
+
+	copied, err := c.Rebase(tip.Sha1[:6], onto.Sha1[:6])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !equalStrings(contents(copied), []string{"a1", "q2", "a2"}) {
+		t.Errorf("Unexpected rebased contents %v", contents(copied))
+	}
+
+	want := []string{"q1", "a1'", "a1", "q2", "a2"}
+	if got := contents(c.MessagesFromHead()); !equalStrings(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+
+	if _, err := c.GetMessageFromSha1(tip.Sha1); err != nil {
+		t.Errorf("Expected the original branch to be kept: %v", err)
+	}
+
+	expectedSha := CalculateSHA1([]string{ChatRoleAssistant, "a1", onto.Sha1})
+	if copied[0].Sha1 != expectedSha {
+		t.Errorf("Expected sha1 %s, but got %s", expectedSha, copied[0].Sha1)
+	}
+
+	if _, err := c.Rebase(q1.Sha1, tip.Sha1); err == nil {
+		t.Errorf("Expected an error when the branch is already on the path")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	c := newTestConversation()
+	q1 := c.Append(ChatRoleUser, "q1")
+	a1 := c.Append(ChatRoleAssistant, "a1")
+	c.Append(ChatRoleUser, "q2")
+	tip := c.Append(ChatRoleAssistant, "a2")
+
+	_, _ = c.ChangeHead(q1.Sha1)
+	c.Append(ChatRoleAssistant, "a1'")
+
+	t.Run("Delete single message re-parents children", func(t *testing.T) {
+		c := &conv{Profile: c.Profile, Messages: append([]Message{}, c.Messages...), Branches: map[string]string{"first": tip.Sha1}}
+		c.Remove([]Message{a1})
+
+		path, err := c.PathTo(c.Branches["first"])
+		if err != nil {
+			t.Fatalf("Expected the branch to follow its tip: %v", err)
+		}
+		if !equalStrings(contents(path), []string{"q1", "q2", "a2"}) {
+			t.Fatalf("Unexpected path %v", contents(path))
+		}
+		q2, a2 := path[1], path[2]
+		if q2.ParentSha1 != q1.Sha1 {
+			t.Errorf("Expected parent %s, but got %s", q1.Sha1, q2.ParentSha1)
+		}
+		// The sha1s of the moved messages are derived from their new parents.
+		if want := CalculateSHA1([]string{ChatRoleUser, "q2", q1.Sha1}); q2.Sha1 != want {
+			t.Errorf("Expected sha1 %s, but got %s", want, q2.Sha1)
+		}
+		if want := CalculateSHA1([]string{ChatRoleAssistant, "a2", q2.Sha1}); a2.Sha1 != want || a2.ParentSha1 != q2.Sha1 {
+			t.Errorf("Expected sha1 %s under %s, but got %s under %s", want, q2.Sha1, a2.Sha1, a2.ParentSha1)
+		}
+	})
+
+	t.Run("Delete subtree moves HEAD", func(t *testing.T) {
+		c := &conv{Profile: c.Profile, Messages: append([]Message{}, c.Messages...)}
+		_, _ = c.ChangeHead(tip.Sha1)
+
+		subtree, err := c.Subtree(a1.Sha1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !equalStrings(contents(subtree), []string{"a1", "q2", "a2"}) {
+			t.Errorf("Unexpected subtree %v", contents(subtree))
+		}
+
+		c.Remove(subtree)
+		if got := contents(c.MessagesFromHead()); !equalStrings(got, []string{"q1"}) {
+			t.Errorf("Expected HEAD to move to q1, but got path %v", got)
+		}
+	})
+
+	t.Run("Prune keeps named branches", func(t *testing.T) {
+		c := &conv{Profile: c.Profile, Messages: append([]Message{}, c.Messages...)}
+		if got := contents(c.Unreachable()); !equalStrings(got, []string{"a1", "q2", "a2"}) {
+			t.Errorf("Unexpected unreachable messages %v", got)
+		}
+
+		if _, err := c.SetBranch("first", tip.Sha1[:6]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := c.Unreachable(); len(got) != 0 {
+			t.Errorf("Expected no unreachable messages, but got %v", contents(got))
+		}
+	})
+}
+
+func TestSystemFromHead(t *testing.T) {
+	c := newTestConversation()
+	c.SetSystem("root prompt")
+	q1 := c.Append(ChatRoleUser, "q1")
+	c.Append(ChatRoleAssistant, "a1")
+
+	_, _ = c.ChangeHead(q1.Sha1)
+	c.Append(ChatRoleSystem, "pirate prompt")
+	c.Append(ChatRoleAssistant, "arr")
+
+	if got := c.SystemFromHead(); got != "pirate prompt" {
+		t.Errorf("Expected the branch prompt, but got %q", got)
+	}
+
+	openAI := c.ToOpenAIMessage()
+	if len(openAI) != 3 || openAI[0].Role != ChatRoleSystem || openAI[0].Content != "pirate prompt" {
+		t.Errorf("Expected the branch prompt to be sent first and the system message to be skipped, but got %v", openAI)
+	}
+
+	if system, messages := c.ToAnthropicMessage(); system != "pirate prompt" || len(messages) != 2 {
+		t.Errorf("Expected the branch prompt and the system message to be skipped, but got %q, %v", system, messages)
+	}
+
+	_, _ = c.ChangeHead(q1.Sha1)
+	if got := c.SystemFromHead(); got != "root prompt" {
+		t.Errorf("Expected the root prompt before the change, but got %q", got)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	c := newTestConversation()
+	c.SetSystem("root prompt")
+	c.Append(ChatRoleUser, "q1")
+	c.Append(ChatRoleAssistant, "a1")
+	q2 := c.Append(ChatRoleUser, "q2")
+	c.Append(ChatRoleAssistant, "a2")
+	original := len(c.Messages)
+
+	summary, err := c.Compact("q1 and a1", q2.Sha1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := contents(c.MessagesFromHead()); !equalStrings(got, []string{"q1", "a1", "q1 and a1", "q2", "a2"}) {
+		t.Errorf("Unexpected path %v", got)
+	}
+
+	system, messages := c.RequestFromHead()
+	if system != "root prompt\n\nSummary of the earlier conversation:\nq1 and a1" {
+		t.Errorf("Expected the summary in the system prompt, but got %q", system)
+	}
+	if got := contents(messages); !equalStrings(got, []string{"q2", "a2"}) {
+		t.Errorf("Expected only the kept messages to be sent, but got %v", got)
+	}
+
+	if len(c.Messages) != original+3 {
+		t.Errorf("Expected the original messages to stay, but got %d messages", len(c.Messages))
+	}
+	if summary.Role != ChatRoleSummary {
+		t.Errorf("Expected a summary message, but got %s", summary.Role)
+	}
+}
+
+func TestAttachedFilesFromHead(t *testing.T) {
+	c := newTestConversation()
+	c.AppendAttachment("a.txt", "one\n", time.Time{})
+	c.Append(ChatRoleUser, "q1")
+	c.AppendAttachments("a.txt changed", []Attachment{{Path: "./a.txt", Hash: HashContents("two\n"), Contents: "two\n"}})
+	c.AppendAttachment("b.txt", "Path: `nested`\n", time.Time{})
+
+	files := c.AttachedFilesFromHead()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+	if got := files[AttachmentPath("a.txt")].Contents; got != "two\n" {
+		t.Errorf("expected latest contents of a.txt, got %q", got)
+	}
+	b := files[AttachmentPath("b.txt")]
+	if b.Contents != "Path: `nested`\n" {
+		t.Errorf("expected contents parsed from the message, got %q", b.Contents)
+	}
+	if !filepath.IsAbs(b.Attachment.Path) {
+		t.Errorf("expected an absolute path to be recorded, got %q", b.Attachment.Path)
+	}
+	if content, ok := ReplaceAttachmentContents(b.Message.Content, "new\n"); !ok || content != FormatAttachment("b.txt", "new\n") {
+		t.Errorf("expected the contents to be replaced keeping the path, got %q", content)
+	}
+	if _, ok := ReplaceAttachmentContents("a.txt changed", "new\n"); ok {
+		t.Errorf("expected a message describing changes not to be replaced")
+	}
+}
@@ -0,0 +1,74 @@
+package conv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CurrentVersion is the schema version written to new history files.
+// When stored data has to be transformed to be read by this version, bump it and append a migration below.
+// New optional fields need no migration.
+const CurrentVersion = 2
+
+type migration struct {
+	// version is the version the conversation has after this migration.
+	version int
+	migrate func(c *conv) error
+}
+
+// migrations are applied in order to conversations whose version is older than the migration's version.
+// Files written before the version field existed are version 0.
+var migrations = []migration{
+	{version: 1, migrate: decodeTabEscapes},
+	{version: 2, migrate: detectAttachments},
+}
+
+func migrate(c *conv) error {
+	if c.Version > CurrentVersion {
+		return fmt.Errorf("history file version %d is newer than the supported version %d, please update aski", c.Version, CurrentVersion)
+	}
+
+	for _, m := range migrations {
+		if c.Version >= m.version {
+			continue
+		}
+
+		if err := m.migrate(c); err != nil {
+			return fmt.Errorf("cannot migrate history file to version %d: %w", m.version, err)
+		}
+		c.Version = m.version
+	}
+
+	return nil
+}
+
+// decodeTabEscapes replaces the "\t" escape sequences that old versions wrote in place of tabs.
+// The escapes cannot be told apart from a literal "\t" in the content, so this is only applied to old files.
+// Newer files are written with raw tabs, see rawTabWriter.
+func decodeTabEscapes(c *conv) error {
+	for i, message := range c.Messages {
+		c.Messages[i].Content = strings.ReplaceAll(message.Content, "\\t", "\t")
+	}
+	return nil
+}
+
+// detectAttachments records the files of messages created by `aski -f` as attachments, so they can be refreshed.
+// The modification time was not recorded, so only the hash is known.
+func detectAttachments(c *conv) error {
+	for i, message := range c.Messages {
+		if message.Role != ChatRoleUser || len(message.Attachments) != 0 {
+			continue
+		}
+
+		path, contents, ok := parseAttachment(message.Content)
+		if !ok {
+			continue
+		}
+
+		c.Messages[i].Attachments = []Attachment{{
+			Path: path,
+			Hash: HashContents(contents),
+		}}
+	}
+	return nil
+}
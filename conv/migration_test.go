@@ -0,0 +1,74 @@
+package conv
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// TestFromYAMLGolden loads history files written by every schema version and compares the migrated
+// result with the golden files. Run `go test ./conv -update` after an intended format change.
+func TestFromYAMLGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "history", "*.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			input, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			c, err := FromYAML(input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := c.ToYAML()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			golden := strings.TrimSuffix(fixture, ".yaml") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("cannot read golden file, run with -update to create it: %v", err)
+			}
+
+			if string(got) != string(expected) {
+				t.Errorf("Expected:\n%s\nbut got:\n%s", expected, got)
+			}
+
+			// A migrated file must load to the same result again.
+			again, err := FromYAML(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if second, _ := again.ToYAML(); string(second) != string(got) {
+				t.Errorf("Expected a stable round trip, but got:\n%s", second)
+			}
+		})
+	}
+}
+
+func TestFromYAMLNewerVersion(t *testing.T) {
+	_, err := FromYAML([]byte("version: 999\nmessages: []\n"))
+	if err == nil {
+		t.Errorf("Expected an error for a history file from a newer version")
+	}
+}
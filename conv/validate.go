@@ -0,0 +1,63 @@
+package conv
+
+import (
+	"fmt"
+	"github.com/goccy/go-yaml"
+	"github.com/kznrluk/aski/config"
+	"sort"
+)
+
+// Validate checks that a history file parses, has no unknown keys and that its message tree is consistent:
+// sha1s are unique, parents exist, there is one HEAD and branches point to messages.
+func Validate(data []byte) []error {
+	var c conv
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return []error{fmt.Errorf("%s", yaml.FormatError(err, false, true))}
+	}
+	if c.Version > CurrentVersion {
+		return []error{fmt.Errorf("version %d is newer than the supported version %d", c.Version, CurrentVersion)}
+	}
+
+	var errs []error
+	keys, err := config.UnknownKeys(data, conv{})
+	if err != nil {
+		return []error{err}
+	}
+	for _, k := range keys {
+		errs = append(errs, k)
+	}
+
+	seen := map[string]bool{}
+	heads := 0
+	for i, m := range c.Messages {
+		if m.Sha1 == "" {
+			errs = append(errs, fmt.Errorf("messages[%d] has no sha1", i))
+		} else if seen[m.Sha1] {
+			errs = append(errs, fmt.Errorf("messages[%d]: duplicate sha1 %s", i, m.Sha1))
+		}
+		seen[m.Sha1] = true
+		if m.Head {
+			heads++
+		}
+	}
+
+	for i, m := range c.Messages {
+		if m.ParentSha1 != "ROOT" && !seen[m.ParentSha1] {
+			errs = append(errs, fmt.Errorf("messages[%d]: parent %s of %.6s not found", i, m.ParentSha1, m.Sha1))
+		}
+	}
+	if len(c.Messages) > 0 && heads != 1 {
+		errs = append(errs, fmt.Errorf("expected one HEAD message, found %d", heads))
+	}
+	var names []string
+	for name := range c.Branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sha1 := c.Branches[name]; !seen[sha1] {
+			errs = append(errs, fmt.Errorf("branch %s points to %s, which is not found", name, sha1))
+		}
+	}
+	return errs
+}
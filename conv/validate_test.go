@@ -0,0 +1,66 @@
+package conv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "history", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			data, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if errs := Validate(data); len(errs) != 0 {
+				t.Errorf("unexpected errors: %v", errs)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := `version: 2
+titel: Pipes
+profile:
+  ProfileName: GPT4
+  Modle: gpt-4
+system: ""
+messages:
+- sha1: aaa
+  parentsha1: ROOT
+  role: user
+  content: What is a pipe?
+  head: true
+- sha1: bbb
+  parentsha1: ccc
+  role: assistant
+  content: A way to connect commands.
+  head: true
+branches:
+  main: ddd
+`
+	expected := []string{
+		"line 2: unknown key titel, did you mean title?",
+		"line 5: unknown key profile.Modle, did you mean Model?",
+		"messages[1]: parent ccc of bbb not found",
+		"expected one HEAD message, found 2",
+		"branch main points to ddd, which is not found",
+	}
+
+	errs := Validate([]byte(data))
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
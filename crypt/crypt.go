@@ -0,0 +1,93 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+
+	// scrypt parameters recommended for interactive logins.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// magic starts every encrypted file. It is followed by the salt, the nonce and the AES-GCM ciphertext.
+var magic = []byte("ASKI-ENCRYPTED-1\n")
+
+var ErrWrongKey = errors.New("cannot decrypt: wrong passphrase or corrupted file")
+
+// IsEncrypted reports whether data was produced by Encrypt.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// DeriveKey derives the AES key from the passphrase.
+func DeriveKey(passphrase []byte, salt []byte) ([]byte, error) {
+	return scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+}
+
+// Encrypt encrypts data with a key derived by DeriveKey from salt.
+func Encrypt(data []byte, key []byte, salt []byte) ([]byte, error) {
+	if len(salt) != saltSize {
+		return nil, fmt.Errorf("salt must be %d bytes", saltSize)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append(append(append([]byte{}, magic...), salt...), nonce...)
+	// The header is authenticated, so the salt cannot be swapped.
+	return gcm.Seal(header, nonce, data, header), nil
+}
+
+// Salt returns the salt of encrypted data, to derive the key for Decrypt.
+func Salt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) || len(data) < len(magic)+saltSize {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	return data[len(magic) : len(magic)+saltSize], nil
+}
+
+func Decrypt(data []byte, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := len(magic) + saltSize + gcm.NonceSize()
+	if !IsEncrypted(data) || len(data) < headerSize {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+
+	header := data[:headerSize]
+	nonce := header[len(magic)+saltSize:]
+	plain, err := gcm.Open(nil, nonce, data[headerSize:], header)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
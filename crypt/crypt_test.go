@@ -0,0 +1,118 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"github.com/kznrluk/aski/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	salt := bytes.Repeat([]byte{1}, saltSize)
+	key, err := DeriveKey([]byte("passphrase"), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("version: 5\nmessages: []\n")
+
+	sealed, err := Encrypt(plain, key, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || IsEncrypted(plain) {
+		t.Fatalf("IsEncrypted does not detect the header")
+	}
+	if bytes.Contains(sealed, plain) {
+		t.Fatalf("plaintext is visible in the encrypted data")
+	}
+
+	got, err := Salt(sealed)
+	if err != nil || !bytes.Equal(got, salt) {
+		t.Fatalf("expected the salt to be recorded, got %v %v", got, err)
+	}
+
+	opened, err := Decrypt(sealed, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("expected %q, got %q", plain, opened)
+	}
+
+	testCases := []struct {
+		name   string
+		key    func() []byte
+		tamper func([]byte) []byte
+	}{
+		{
+			name: "Wrong passphrase",
+			key: func() []byte {
+				k, _ := DeriveKey([]byte("wrong"), salt)
+				return k
+			},
+			tamper: func(b []byte) []byte { return b },
+		},
+		{
+			name:   "Modified salt",
+			key:    func() []byte { return key },
+			tamper: func(b []byte) []byte { b[len(magic)] ^= 1; return b },
+		},
+		{
+			name:   "Modified ciphertext",
+			key:    func() []byte { return key },
+			tamper: func(b []byte) []byte { b[len(b)-1] ^= 1; return b },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.tamper(append([]byte{}, sealed...))
+			if _, err := Decrypt(data, tc.key()); err != ErrWrongKey {
+				t.Errorf("expected ErrWrongKey, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSealChecksPassphrase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useKey := func(p string) {
+		t.Setenv(EnvPassphrase, p)
+		passphrase, passphraseErr = nil, nil
+		keys = map[string][]byte{}
+	}
+
+	useKey("right")
+	sealed, err := Seal([]byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useKey("wrong")
+	if _, err := Seal([]byte("second")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey for another passphrase, got %v", err)
+	}
+
+	// Without history.check, an existing history file is checked instead.
+	if err := os.Remove(filepath.Join(config.MustGetAskiDir(), "history.check")); err != nil {
+		t.Fatal(err)
+	}
+	dir := config.MustGetHistoryDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "first.yaml"), sealed, 0600); err != nil {
+		t.Fatal(err)
+	}
+	useKey("wrong")
+	if _, err := Seal([]byte("second")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey against the history file, got %v", err)
+	}
+
+	useKey("right")
+	if _, err := Seal([]byte("second")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kznrluk/aski/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// EnvPassphrase is the environment variable holding the passphrase for history files.
+const EnvPassphrase = "ASKI_HISTORY_KEY"
+
+var (
+	passphrase    []byte
+	passphraseErr error
+	// keys caches derived keys by salt, as deriving them is slow on purpose.
+	keys = map[string][]byte{}
+)
+
+// settings are the values of the config this package uses. They are read once, as history files are saved often.
+var settings struct {
+	once           sync.Once
+	encrypt        bool
+	historyKeyFile string
+}
+
+// Configure sets the config values used by this package, so that it does not read the config again.
+func Configure(cfg config.Config) {
+	settings.once.Do(func() {})
+	settings.encrypt = cfg.EncryptHistory
+	settings.historyKeyFile = cfg.HistoryKeyFile
+}
+
+// loadSettings reads the config on first use unless Configure was called.
+func loadSettings() {
+	settings.once.Do(func() {
+		if cfg, err := config.GetConfig(); err == nil {
+			settings.encrypt = cfg.EncryptHistory
+			settings.historyKeyFile = cfg.HistoryKeyFile
+		}
+	})
+}
+
+// Enabled reports whether new history files should be encrypted.
+func Enabled() bool {
+	loadSettings()
+	return settings.encrypt
+}
+
+// Seal encrypts a history file.
+func Seal(data []byte) ([]byte, error) {
+	salt, err := installSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := key(salt, true)
+	if err != nil {
+		return nil, err
+	}
+	return Encrypt(data, key, salt)
+}
+
+// Open decrypts a history file if it is encrypted, and returns other files as is.
+func Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	salt, err := Salt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := key(salt, false)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, key)
+}
+
+func key(salt []byte, confirm bool) ([]byte, error) {
+	if k, ok := keys[string(salt)]; ok {
+		return k, nil
+	}
+
+	p, err := getPassphrase(confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := DeriveKey(p, salt)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKey(salt, k); err != nil {
+		// Forget the passphrase, so a mistyped one is asked again instead of being used for new files.
+		passphrase, passphraseErr = nil, nil
+		return nil, err
+	}
+	keys[string(salt)] = k
+	return k, nil
+}
+
+// checkKey verifies a key for the salt of history.salt, which all new files share, so that a mistyped passphrase
+// does not encrypt files that the others cannot be read with. The key is checked against history.check,
+// or, if that does not exist yet, against an existing file, before history.check is created with it.
+func checkKey(salt []byte, k []byte) error {
+	installed, err := os.ReadFile(saltPath())
+	if err != nil || !bytes.Equal(installed, salt) {
+		// Files with other salts are checked by decrypting them.
+		return nil
+	}
+
+	path := filepath.Join(config.MustGetAskiDir(), "history.check")
+	check, err := os.ReadFile(path)
+	exists := err == nil
+	if !exists {
+		check = encryptedHistoryFile(salt)
+	}
+	if check != nil {
+		if _, err := Decrypt(check, k); err != nil {
+			return fmt.Errorf("the passphrase does not match the one of the encrypted history: %w", err)
+		}
+	}
+	if exists {
+		return nil
+	}
+
+	sealed, err := Encrypt([]byte(checkValue), k, salt)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, 0600)
+}
+
+// checkValue is the contents of history.check, which only needs to decrypt.
+const checkValue = "aski history key check"
+
+// encryptedHistoryFile returns an existing history file encrypted with the salt, or nil if there is none.
+func encryptedHistoryFile(salt []byte) []byte {
+	for _, dir := range config.HistoryDirs() {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".yaml") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+			if err != nil || !IsEncrypted(data) {
+				continue
+			}
+			if s, err := Salt(data); err == nil && bytes.Equal(s, salt) {
+				return data
+			}
+		}
+	}
+	return nil
+}
+
+// PassphraseAvailable reports whether encrypted history files can be read without a prompt.
+func PassphraseAvailable() bool {
+	if passphrase != nil || os.Getenv(EnvPassphrase) != "" {
+		return true
+	}
+	loadSettings()
+	return settings.historyKeyFile != ""
+}
+
+// getPassphrase reads the passphrase from the environment, the key file or a prompt, once per process.
+// When a new passphrase is typed to encrypt, it is asked twice.
+func getPassphrase(confirm bool) ([]byte, error) {
+	if passphrase != nil || passphraseErr != nil {
+		return passphrase, passphraseErr
+	}
+
+	passphrase, passphraseErr = readPassphrase(confirm)
+	return passphrase, passphraseErr
+}
+
+func readPassphrase(confirm bool) ([]byte, error) {
+	if p := os.Getenv(EnvPassphrase); p != "" {
+		return []byte(p), nil
+	}
+
+	loadSettings()
+	if keyFile := settings.historyKeyFile; keyFile != "" {
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read HistoryKeyFile: %w", err)
+		}
+		p := strings.TrimSpace(string(b))
+		if p == "" {
+			return nil, fmt.Errorf("HistoryKeyFile is empty: %s", keyFile)
+		}
+		return []byte(p), nil
+	}
+
+	var p string
+	if err := survey.AskOne(&survey.Password{Message: "History passphrase:"}, &p, survey.WithValidator(survey.Required)); err != nil {
+		return nil, fmt.Errorf("no passphrase for history files. Set %s or HistoryKeyFile in config.yaml: %w", EnvPassphrase, err)
+	}
+
+	if confirm {
+		var again string
+		if err := survey.AskOne(&survey.Password{Message: "Repeat passphrase:"}, &again); err != nil {
+			return nil, err
+		}
+		if again != p {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return []byte(p), nil
+}
+
+// installSalt returns the salt used to encrypt new files, creating it on first use.
+// Sharing it lets the key be derived once per process. Each file also records its salt, so losing this file is harmless.
+func installSalt() ([]byte, error) {
+	path := saltPath()
+	if salt, err := os.ReadFile(path); err == nil && len(salt) == saltSize {
+		return salt, nil
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, salt, 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func saltPath() string {
+	return filepath.Join(config.MustGetAskiDir(), "history.salt")
+}
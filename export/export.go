@@ -0,0 +1,203 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+)
+
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+
+	// SchemaVersion identifies the layout of Document. Fields may be added, but never renamed or removed.
+	SchemaVersion = "aski.export/v1"
+)
+
+type (
+	// Options selects which part of the conversation tree is exported.
+	// By default only the path from ROOT to HEAD is exported.
+	Options struct {
+		// Branch is a sha1 prefix or a branch name. The path from ROOT to it is exported.
+		Branch string
+		// All exports the whole tree, including every branch.
+		All bool
+	}
+
+	// Document is the JSON export schema.
+	Document struct {
+		// Schema is always SchemaVersion.
+		Schema  string `json:"schema"`
+		Profile string `json:"profile"`
+		Model   string `json:"model"`
+		// System is the system prompt at ROOT. Messages with the system role replace it for their descendants.
+		System string `json:"system"`
+		// Head is the sha1 of the HEAD message, or "ROOT" when there is none.
+		Head string `json:"head"`
+		// Messages are in the order they were appended. ParentSha1 links them into a tree whose root is "ROOT".
+		Messages []Message `json:"messages"`
+	}
+
+	Message struct {
+		Sha1       string `json:"sha1"`
+		ParentSha1 string `json:"parent_sha1"`
+		Role       string `json:"role"`
+		UserName   string `json:"user_name,omitempty"`
+		Content    string `json:"content"`
+	}
+)
+
+// NormalizeFormat converts user input such as "markdown" into one of the Format constants.
+func NormalizeFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "html", "htm":
+		return FormatHTML, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown export format: %s (md, html or json)", format)
+	}
+}
+
+func Render(cv conv.Conversation, format string, opts Options) ([]byte, error) {
+	format, err := NormalizeFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := selectMessages(cv, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatMarkdown:
+		return Markdown(cv, messages, opts.All), nil
+	case FormatHTML:
+		return HTML(cv, messages, opts.All)
+	default:
+		return JSON(cv, messages)
+	}
+}
+
+func selectMessages(cv conv.Conversation, opts Options) ([]conv.Message, error) {
+	if opts.All {
+		return cv.GetMessages(), nil
+	}
+
+	if opts.Branch == "" {
+		return cv.MessagesFromHead(), nil
+	}
+
+	target := opts.Branch
+	if sha, ok := cv.GetBranches()[target]; ok {
+		target = sha
+	}
+
+	return cv.PathTo(target)
+}
+
+func Markdown(cv conv.Conversation, messages []conv.Message, all bool) []byte {
+	profile := cv.GetProfile()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s (%s)\n\n", profile.ProfileName, profile.Model))
+
+	if system := cv.GetSystem(); system != "" {
+		sb.WriteString("## System\n\n")
+		sb.WriteString(system)
+		sb.WriteString("\n\n")
+	}
+
+	if !all {
+		writeMarkdownTranscript(&sb, messages)
+		return []byte(sb.String())
+	}
+
+	for _, leaf := range leaves(messages) {
+		sb.WriteString(fmt.Sprintf("## Branch [%.6s]\n\n", leaf.Sha1))
+		path, err := cv.PathTo(leaf.Sha1)
+		if err != nil {
+			continue
+		}
+		writeMarkdownTranscript(&sb, path)
+	}
+
+	return []byte(sb.String())
+}
+
+func writeMarkdownTranscript(sb *strings.Builder, messages []conv.Message) {
+	for _, m := range messages {
+		sb.WriteString(fmt.Sprintf("### %s\n\n", roleTitle(m)))
+		sb.WriteString(strings.TrimSpace(m.Content))
+		sb.WriteString("\n\n")
+	}
+}
+
+func JSON(cv conv.Conversation, messages []conv.Message) ([]byte, error) {
+	profile := cv.GetProfile()
+	doc := Document{
+		Schema:   SchemaVersion,
+		Profile:  profile.ProfileName,
+		Model:    profile.Model,
+		System:   cv.GetSystem(),
+		Head:     "ROOT",
+		Messages: []Message{},
+	}
+
+	if path := cv.MessagesFromHead(); len(path) > 0 {
+		doc.Head = path[len(path)-1].Sha1
+	}
+
+	for _, m := range messages {
+		doc.Messages = append(doc.Messages, Message{
+			Sha1:       m.Sha1,
+			ParentSha1: m.ParentSha1,
+			Role:       m.Role,
+			UserName:   m.UserName,
+			Content:    m.Content,
+		})
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// leaves returns the messages that have no children, in the order they were appended.
+func leaves(messages []conv.Message) []conv.Message {
+	hasChild := map[string]bool{}
+	for _, m := range messages {
+		hasChild[m.ParentSha1] = true
+	}
+
+	var result []conv.Message
+	for _, m := range messages {
+		if !hasChild[m.Sha1] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func roleTitle(m conv.Message) string {
+	title := m.Role
+	if title != "" {
+		title = strings.ToUpper(title[:1]) + title[1:]
+	}
+	if m.UserName != "" {
+		title = fmt.Sprintf("%s (%s)", title, m.UserName)
+	}
+	return title
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"encoding/json"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestConversation() (conv.Conversation, conv.Message) {
+	cv := conv.NewConversation(config.Profile{ProfileName: "Test", Model: "gpt-4", UserName: "tester"})
+	cv.SetSystem("be kind")
+	q := cv.Append(conv.ChatRoleUser, "question")
+	alt := cv.Append(conv.ChatRoleAssistant, "first answer")
+	_, _ = cv.ChangeHead(q.Sha1)
+	cv.Append(conv.ChatRoleAssistant, "second answer")
+	return cv, alt
+}
+
+func TestJSONSchema(t *testing.T) {
+	cv, _ := newTestConversation()
+
+	data, err := Render(cv, "json", Options{All: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, k := range []string{"schema", "profile", "model", "system", "head", "messages"} {
+		if _, ok := doc[k]; !ok {
+			t.Errorf("Expected key %s in %v", k, doc)
+		}
+	}
+
+	if doc["schema"] != SchemaVersion {
+		t.Errorf("Expected schema %s, but got %v", SchemaVersion, doc["schema"])
+	}
+
+	messages := doc["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, but got %d", len(messages))
+	}
+
+	first := messages[0].(map[string]interface{})
+	expected := map[string]interface{}{
+		"sha1":        first["sha1"],
+		"parent_sha1": "ROOT",
+		"role":        "user",
+		"user_name":   "tester",
+		"content":     "question",
+	}
+	if !reflect.DeepEqual(first, expected) {
+		t.Errorf("Expected %v, but got %v", expected, first)
+	}
+}
+
+func TestMarkdownBranchSelection(t *testing.T) {
+	cv, alt := newTestConversation()
+
+	testCases := []struct {
+		name     string
+		opts     Options
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "HEAD branch",
+			opts:     Options{},
+			contains: []string{"## System", "### User (tester)", "second answer"},
+			excludes: []string{"first answer"},
+		},
+		{
+			name:     "Specific branch",
+			opts:     Options{Branch: alt.Sha1[:6]},
+			contains: []string{"first answer"},
+			excludes: []string{"second answer"},
+		},
+		{
+			name:     "All branches",
+			opts:     Options{All: true},
+			contains: []string{"first answer", "second answer", "## Branch"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Render(cv, "markdown", tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			out := string(data)
+			for _, s := range tc.contains {
+				if !strings.Contains(out, s) {
+					t.Errorf("Expected output to contain %q:\n%s", s, out)
+				}
+			}
+			for _, s := range tc.excludes {
+				if strings.Contains(out, s) {
+					t.Errorf("Expected output not to contain %q:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
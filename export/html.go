@@ -0,0 +1,236 @@
+package export
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/kznrluk/aski/conv"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+type (
+	// segment is a linear run of messages followed by the branches that fork from its last message.
+	segment struct {
+		Messages []htmlMessage
+		Branches []*segment
+		Open     bool
+	}
+
+	htmlMessage struct {
+		Sha1     string
+		Role     string
+		Title    string
+		Head     bool
+		Body     template.HTML
+		Headline string
+	}
+
+	htmlDocument struct {
+		Title  string
+		Model  string
+		System template.HTML
+		Root   *segment
+	}
+
+	// codeBlockRenderer highlights fenced code blocks with inline styles so the document stays self-contained.
+	codeBlockRenderer struct{}
+)
+
+var htmlTemplate = template.Must(template.New("document").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 900px; margin: 2em auto; padding: 0 1em; color: #24292f; }
+.message { border-left: 4px solid #d0d7de; margin: 1em 0; padding: 0.2em 1em; }
+.message.user { border-color: #0969da; }
+.message.assistant { border-color: #1a7f37; }
+.message.system { border-color: #9a6700; }
+.header { font-size: 0.85em; color: #57606a; }
+.sha1 { font-family: monospace; }
+.head { color: #cf222e; font-weight: bold; }
+details { margin-left: 1em; border-left: 1px dashed #d0d7de; padding-left: 1em; }
+summary { cursor: pointer; color: #57606a; }
+pre { padding: 0.8em; overflow-x: auto; border-radius: 6px; background: #f6f8fa; }
+code { font-family: SFMono-Regular, Consolas, monospace; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="header">Model: {{.Model}}</p>
+{{if .System}}<div class="message system"><p class="header">System</p>{{.System}}</div>{{end}}
+{{template "segment" .Root}}
+</body>
+</html>
+{{define "segment"}}{{range .Messages}}<div class="message {{.Role}}" id="{{.Sha1}}">
+<p class="header">{{.Title}} <span class="sha1">[{{printf "%.6s" .Sha1}}]</span>{{if .Head}} <span class="head">HEAD</span>{{end}}</p>
+{{.Body}}
+</div>
+{{end}}{{range .Branches}}<details{{if .Open}} open{{end}}>
+<summary>Branch{{with index .Messages 0}} <span class="sha1">[{{printf "%.6s" .Sha1}}]</span> {{.Headline}}{{end}}</summary>
+{{template "segment" .}}
+</details>
+{{end}}{{end}}`))
+
+func HTML(cv conv.Conversation, messages []conv.Message, all bool) ([]byte, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100))),
+	)
+
+	profile := cv.GetProfile()
+	doc := htmlDocument{
+		Title: profile.ProfileName,
+		Model: profile.Model,
+	}
+
+	if system := cv.GetSystem(); system != "" {
+		body, err := renderMarkdown(md, system)
+		if err != nil {
+			return nil, err
+		}
+		doc.System = body
+	}
+
+	open := map[string]bool{}
+	for _, m := range cv.MessagesFromHead() {
+		open[m.Sha1] = true
+	}
+
+	children := map[string][]conv.Message{}
+	for _, m := range messages {
+		children[m.ParentSha1] = append(children[m.ParentSha1], m)
+	}
+
+	if !all && len(messages) > 0 {
+		// A single path: make it the only child of its first parent.
+		children = map[string][]conv.Message{messages[0].ParentSha1: {messages[0]}}
+		for i := 1; i < len(messages); i++ {
+			children[messages[i-1].Sha1] = []conv.Message{messages[i]}
+		}
+	}
+
+	root := &segment{Open: true}
+	start := "ROOT"
+	if len(messages) > 0 {
+		start = messages[0].ParentSha1
+	}
+
+	if err := buildSegment(md, root, start, children, open); err != nil {
+		return nil, err
+	}
+	doc.Root = root
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, doc); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func buildSegment(md goldmark.Markdown, seg *segment, parent string, children map[string][]conv.Message, open map[string]bool) error {
+	for {
+		next := children[parent]
+		if len(next) != 1 {
+			for _, child := range next {
+				branch := &segment{Open: open[child.Sha1]}
+				if err := appendSegmentMessage(md, branch, child); err != nil {
+					return err
+				}
+				if err := buildSegment(md, branch, child.Sha1, children, open); err != nil {
+					return err
+				}
+				seg.Branches = append(seg.Branches, branch)
+			}
+			return nil
+		}
+
+		if err := appendSegmentMessage(md, seg, next[0]); err != nil {
+			return err
+		}
+		parent = next[0].Sha1
+	}
+}
+
+func appendSegmentMessage(md goldmark.Markdown, seg *segment, m conv.Message) error {
+	body, err := renderMarkdown(md, m.Content)
+	if err != nil {
+		return err
+	}
+
+	headline := strings.TrimSpace(strings.SplitN(strings.TrimSpace(m.Content), "\n", 2)[0])
+	if len([]rune(headline)) > 60 {
+		headline = string([]rune(headline)[:60]) + "..."
+	}
+
+	seg.Messages = append(seg.Messages, htmlMessage{
+		Sha1:     m.Sha1,
+		Role:     m.Role,
+		Title:    roleTitle(m),
+		Head:     m.Head,
+		Body:     body,
+		Headline: headline,
+	})
+	return nil
+}
+
+func renderMarkdown(md goldmark.Markdown, content string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(content), &buf); err != nil {
+		return "", err
+	}
+	// goldmark escapes raw HTML unless the unsafe option is set, so the output is safe to embed.
+	return template.HTML(buf.String()), nil
+}
+
+func (r codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+	var code strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	var lexer chroma.Lexer
+	if language := n.Language(source); language != nil {
+		lexer = lexers.Get(string(language))
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(code.String())
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	formatter := chromahtml.New(chromahtml.WithClasses(false))
+	if err := formatter.Format(w, styles.Get("github"), iterator); err != nil {
+		return ast.WalkStop, err
+	}
+
+	return ast.WalkSkipChildren, nil
+}
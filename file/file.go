@@ -1,9 +1,11 @@
 package file
 
 import (
+	"fmt"
 	"github.com/kznrluk/aski/util"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type FileContents struct {
@@ -11,6 +13,7 @@ type FileContents struct {
 	Path     string
 	Contents string
 	Length   int
+	ModTime  time.Time
 }
 
 func GetFileContents(fileGlobs []string) []FileContents {
@@ -21,27 +24,43 @@ func GetFileContents(fileGlobs []string) []FileContents {
 			panic(err)
 		}
 		for _, file := range files {
-			contentsBytes, err := os.ReadFile(file)
+			contents, err := Read(file)
 			if err != nil {
+				if err == errBinary {
+					continue
+				}
 				panic(err)
 			}
-			content := string(contentsBytes)
-			if util.IsBinary(contentsBytes) {
-				continue
-			}
 
-			info, err := os.Stat(file)
-			if err != nil {
-				panic(err)
-			}
-
-			fileContents = append(fileContents, FileContents{
-				Name:     info.Name(),
-				Path:     file,
-				Contents: content,
-				Length:   len(content),
-			})
+			fileContents = append(fileContents, contents)
 		}
 	}
 	return fileContents
 }
+
+var errBinary = fmt.Errorf("binary file")
+
+// Read reads a single text file.
+func Read(file string) (FileContents, error) {
+	contentsBytes, err := os.ReadFile(file)
+	if err != nil {
+		return FileContents{}, err
+	}
+	if util.IsBinary(contentsBytes) {
+		return FileContents{}, errBinary
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return FileContents{}, err
+	}
+
+	content := string(contentsBytes)
+	return FileContents{
+		Name:     info.Name(),
+		Path:     file,
+		Contents: content,
+		Length:   len(content),
+		ModTime:  info.ModTime(),
+	}, nil
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/config"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Expired is a conversation to be deleted by the retention policy.
+type Expired struct {
+	Entry
+	Reason string `json:"reason"`
+}
+
+// Exempt reports whether the conversation is never deleted by the retention policy.
+func (e Entry) Exempt() bool {
+	return e.Keep || len(e.Tags) != 0
+}
+
+// Collect returns the entries that exceed the retention limits at now, least recently continued first.
+// Conversations of a profile with its own limits are counted separately from the rest.
+// Exempt conversations are neither deleted nor counted towards MaxCount and MaxSize.
+func Collect(entries []Entry, retention config.Retention, now time.Time) ([]Expired, error) {
+	type group struct {
+		limits  config.Retention
+		entries []Entry
+	}
+	groups := map[string]*group{}
+	var order []string
+
+	for _, e := range entries {
+		limits, ok := retention.ForProfile(e.Profile)
+		key := ""
+		if ok {
+			key = strings.ToLower(e.Profile)
+		}
+		if groups[key] == nil {
+			groups[key] = &group{limits: limits}
+			order = append(order, key)
+		}
+		groups[key].entries = append(groups[key].entries, e)
+	}
+
+	var expired []Expired
+	for _, key := range order {
+		g := groups[key]
+		e, err := collectGroup(g.entries, g.limits, now)
+		if err != nil {
+			if key != "" {
+				return nil, fmt.Errorf("profile %s: %w", key, err)
+			}
+			return nil, err
+		}
+		expired = append(expired, e...)
+	}
+
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].Age(now) > expired[j].Age(now)
+	})
+	return expired, nil
+}
+
+// collectGroup applies the limits to the entries of a group, most recently continued first.
+func collectGroup(entries []Entry, limits config.Retention, now time.Time) ([]Expired, error) {
+	maxAge, err := limits.Age()
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := limits.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	// A long conversation that is still continued is kept, however long ago it started.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Age(now) < entries[j].Age(now)
+	})
+
+	var expired []Expired
+	count := 0
+	size := int64(0)
+	for _, e := range entries {
+		if e.Exempt() {
+			continue
+		}
+
+		switch {
+		case maxAge > 0 && e.Age(now) > maxAge:
+			expired = append(expired, Expired{Entry: e, Reason: fmt.Sprintf("older than %s", limits.MaxAge)})
+		case limits.MaxCount > 0 && count >= limits.MaxCount:
+			expired = append(expired, Expired{Entry: e, Reason: fmt.Sprintf("more than %d conversations", limits.MaxCount)})
+		case maxSize > 0 && size+e.Size > maxSize:
+			expired = append(expired, Expired{Entry: e, Reason: fmt.Sprintf("more than %s in total", limits.MaxSize)})
+		default:
+			count++
+			size += e.Size
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,108 @@
+package history
+
+import (
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	entries := []Entry{
+		{File: "new.yaml", Profile: "GPT4", Date: now.Add(-1 * day), Size: 100},
+		{File: "week.yaml", Profile: "GPT4", Date: now.Add(-7 * day), Size: 100},
+		{File: "kept.yaml", Profile: "GPT4", Date: now.Add(-60 * day), Size: 100, Keep: true},
+		{File: "tagged.yaml", Profile: "GPT4", Date: now.Add(-60 * day), Size: 100, Tags: []string{"work"}},
+		{File: "month.yaml", Profile: "GPT4", Date: now.Add(-30 * day), Size: 100},
+		{File: "claude.yaml", Profile: "Claude", Date: now.Add(-30 * day), Size: 100},
+	}
+
+	testCases := []struct {
+		name      string
+		retention config.Retention
+		expected  []string
+	}{
+		{name: "No limits", retention: config.Retention{}, expected: nil},
+		{name: "MaxAge", retention: config.Retention{MaxAge: "14d"}, expected: []string{"month.yaml", "claude.yaml"}},
+		{name: "MaxCount keeps newest", retention: config.Retention{MaxCount: 2}, expected: []string{"month.yaml", "claude.yaml"}},
+		{name: "MaxSize", retention: config.Retention{MaxSize: "250B"}, expected: []string{"month.yaml", "claude.yaml"}},
+		{
+			name: "Profile override is counted separately",
+			retention: config.Retention{
+				MaxCount: 1,
+				Profiles: map[string]config.Retention{"claude": {MaxCount: 5}},
+			},
+			expected: []string{"month.yaml", "week.yaml"},
+		},
+		{
+			name: "Profile override falls back to global limits",
+			retention: config.Retention{
+				MaxAge:   "2w",
+				Profiles: map[string]config.Retention{"GPT4": {MaxCount: 1}},
+			},
+			expected: []string{"month.yaml", "claude.yaml", "week.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expired, err := Collect(entries, tc.retention, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var files []string
+			for _, e := range expired {
+				files = append(files, e.File)
+			}
+			if !reflect.DeepEqual(files, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, files)
+			}
+		})
+	}
+}
+
+func TestCollectInvalidLimits(t *testing.T) {
+	entries := []Entry{{File: "a.yaml", Profile: "GPT4", Date: time.Now()}}
+	for _, r := range []config.Retention{{MaxAge: "soon"}, {MaxSize: "big"}} {
+		if _, err := Collect(entries, r, time.Now()); err == nil {
+			t.Errorf("expected an error for %+v", r)
+		}
+	}
+}
+
+func TestCollectAgesByLastActivity(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	cv := conv.NewConversation(config.Profile{ProfileName: "GPT4"})
+	first := cv.Append(conv.ChatRoleUser, "started long ago")
+	first.CreatedAt = now.Add(-60 * day)
+	if err := cv.Modify(first); err != nil {
+		t.Fatal(err)
+	}
+	cv.Append(conv.ChatRoleAssistant, "and still continued")
+
+	stale := conv.NewConversation(config.Profile{ProfileName: "GPT4"})
+	only := stale.Append(conv.ChatRoleUser, "abandoned")
+	only.CreatedAt = now.Add(-60 * day)
+	if err := stale.Modify(only); err != nil {
+		t.Fatal(err)
+	}
+
+	active := NewEntry("active.yaml", time.Time{}, cv)
+	if !active.Date.Equal(first.CreatedAt) || active.Age(now) > day {
+		t.Fatalf("expected the date of the first message and a recent last activity, got %+v", active)
+	}
+
+	entries := []Entry{active, NewEntry("stale.yaml", time.Time{}, stale)}
+	expired, err := Collect(entries, config.Retention{MaxAge: "30d"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].File != "stale.yaml" {
+		t.Errorf("expected only stale.yaml to expire, got %+v", expired)
+	}
+}
@@ -0,0 +1,257 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/crypt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileDateLayout is the layout of the timestamp that history file names start with.
+const fileDateLayout = "20060102-150405"
+
+type (
+	// Entry summarizes a history file.
+	Entry struct {
+		File  string    `json:"file"`
+		Path  string    `json:"path"`
+		Title string    `json:"title"`
+		Date  time.Time `json:"date"`
+		// LastActive is when the conversation was last continued, see Age.
+		LastActive   time.Time `json:"last_active"`
+		Profile      string    `json:"profile"`
+		Model        string    `json:"model"`
+		FirstMessage string    `json:"first_message"`
+		Messages     int       `json:"messages"`
+		Branches     int       `json:"branches"`
+		Size         int64     `json:"size"`
+		Keep         bool      `json:"keep,omitempty"`
+		Tags         []string  `json:"tags,omitempty"`
+
+		conversation conv.Conversation
+	}
+
+	// Filter selects entries. Zero values match everything.
+	Filter struct {
+		Profile string
+		Model   string
+		// Since and Until are inclusive.
+		Since time.Time
+		Until time.Time
+	}
+
+	// Match is a line of a message that matches a search.
+	Match struct {
+		File string `json:"file"`
+		Sha1 string `json:"sha1"`
+		Role string `json:"role"`
+		Line string `json:"line"`
+	}
+)
+
+// ErrEncrypted is returned by LoadUnencrypted for encrypted files.
+var ErrEncrypted = errors.New("encrypted")
+
+// Load reads every history file in dir, newest first.
+// Files that cannot be parsed are returned in skipped instead of failing the whole load.
+func Load(dir string) (entries []Entry, skipped []error, err error) {
+	return load(dir, true)
+}
+
+// LoadUnencrypted is like Load, but skips encrypted files with ErrEncrypted instead of asking for the passphrase.
+func LoadUnencrypted(dir string) (entries []Entry, skipped []error, err error) {
+	return load(dir, false)
+}
+
+func load(dir string, decrypt bool) (entries []Entry, skipped []error, err error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".yaml") {
+			continue
+		}
+
+		e, err := loadFile(filepath.Join(dir, file.Name()), decrypt)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	Sort(entries)
+	return entries, skipped, nil
+}
+
+// LoadFile reads a single history file.
+func LoadFile(path string) (Entry, error) {
+	return loadFile(path, true)
+}
+
+func loadFile(path string, decrypt bool) (Entry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	if !decrypt && crypt.IsEncrypted(raw) {
+		return Entry{}, fmt.Errorf("%s: %w", filepath.Base(path), ErrEncrypted)
+	}
+
+	data, err := crypt.Open(raw)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	cv, err := conv.FromYAML(data)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	e := NewEntry(filepath.Base(path), modTime, cv)
+	e.Path = path
+	e.Size = int64(len(raw))
+	return e, nil
+}
+
+// Sort orders entries newest first.
+func Sort(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.After(entries[j].Date)
+	})
+}
+
+// NewEntry summarizes the conversation saved as file.
+// The date is the first message, falling back to the file name and then to modTime.
+// The last activity is the newest message, falling back to modTime.
+func NewEntry(file string, modTime time.Time, cv conv.Conversation) Entry {
+	messages := cv.GetMessages()
+	profile := cv.GetProfile()
+
+	e := Entry{
+		File:         file,
+		Title:        cv.GetTitle(),
+		Date:         modTime,
+		LastActive:   modTime,
+		Profile:      profile.ProfileName,
+		Model:        profile.Model,
+		Messages:     len(messages),
+		Keep:         cv.GetKeep(),
+		Tags:         cv.GetTags(),
+		conversation: cv,
+	}
+
+	if len(file) >= len(fileDateLayout) {
+		if t, err := time.ParseInLocation(fileDateLayout, file[:len(fileDateLayout)], time.Local); err == nil {
+			e.Date = t
+		}
+	}
+	for _, m := range messages {
+		if !m.CreatedAt.IsZero() {
+			e.Date = m.CreatedAt
+			break
+		}
+	}
+	newest := time.Time{}
+	for _, m := range messages {
+		if m.CreatedAt.After(newest) {
+			newest = m.CreatedAt
+		}
+	}
+	if !newest.IsZero() {
+		e.LastActive = newest
+	}
+
+	for _, m := range messages {
+		if m.Role == conv.ChatRoleUser && len(m.Attachments) == 0 {
+			e.FirstMessage = m.Content
+			break
+		}
+	}
+
+	hasChild := map[string]bool{}
+	for _, m := range messages {
+		hasChild[m.ParentSha1] = true
+	}
+	for _, m := range messages {
+		if !hasChild[m.Sha1] {
+			e.Branches++
+		}
+	}
+
+	return e
+}
+
+// Age returns how long ago the conversation was last continued at now. Conversations without a known last
+// activity are aged by their date.
+func (e Entry) Age(now time.Time) time.Duration {
+	last := e.Date
+	if e.LastActive.After(last) {
+		last = e.LastActive
+	}
+	return now.Sub(last)
+}
+
+func (e Entry) Conversation() conv.Conversation {
+	return e.conversation
+}
+
+func (f Filter) Match(e Entry) bool {
+	if f.Profile != "" && !strings.EqualFold(f.Profile, e.Profile) {
+		return false
+	}
+	if f.Model != "" && !strings.Contains(strings.ToLower(e.Model), strings.ToLower(f.Model)) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Date.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Date.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+func Apply(entries []Entry, f Filter) []Entry {
+	var result []Entry
+	for _, e := range entries {
+		if f.Match(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Search returns the lines of all messages, on every branch, that match re.
+func Search(entries []Entry, re *regexp.Regexp) []Match {
+	var matches []Match
+	for _, e := range entries {
+		for _, m := range e.conversation.GetMessages() {
+			for _, line := range strings.Split(m.Content, "\n") {
+				if re.MatchString(line) {
+					matches = append(matches, Match{
+						File: e.File,
+						Sha1: m.Sha1,
+						Role: m.Role,
+						Line: line,
+					})
+				}
+			}
+		}
+	}
+	return matches
+}
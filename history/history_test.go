@@ -0,0 +1,138 @@
+package history
+
+import (
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func writeConversation(t *testing.T, dir string, file string, profile string, model string, messages ...string) {
+	t.Helper()
+	cv := conv.NewConversation(config.Profile{ProfileName: profile, Model: model})
+	for i, m := range messages {
+		role := conv.ChatRoleUser
+		if i%2 == 1 {
+			role = conv.ChatRoleAssistant
+		}
+		cv.Append(role, m)
+	}
+
+	data, err := cv.ToYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, file), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadAndFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeConversation(t, dir, "a.yaml", "GPT4", "gpt-4-turbo-preview", "hello", "hi")
+	writeConversation(t, dir, "b.yaml", "Claude", "claude-3-opus-20240229", "what is a pipe?\nin shells", "a | b")
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not history"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, skipped, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Fatalf("unexpected skipped files: %v", skipped)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{name: "No filter", filter: Filter{}, expected: []string{"a.yaml", "b.yaml"}},
+		{name: "Profile is case insensitive", filter: Filter{Profile: "claude"}, expected: []string{"b.yaml"}},
+		{name: "Model matches part", filter: Filter{Model: "gpt-4"}, expected: []string{"a.yaml"}},
+		{name: "Since excludes older", filter: Filter{Since: time.Now().Add(time.Hour)}, expected: nil},
+		{name: "Until includes older", filter: Filter{Until: time.Now().Add(time.Hour)}, expected: []string{"a.yaml", "b.yaml"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			found := map[string]bool{}
+			for _, e := range Apply(entries, tc.filter) {
+				found[e.File] = true
+			}
+			if len(found) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, found)
+			}
+			for _, f := range tc.expected {
+				if !found[f] {
+					t.Errorf("expected %s in %v", f, found)
+				}
+			}
+		})
+	}
+
+	for _, e := range entries {
+		if e.File == "b.yaml" {
+			if e.FirstMessage != "what is a pipe?\nin shells" || e.Messages != 2 || e.Branches != 1 {
+				t.Errorf("unexpected entry: %+v", e)
+			}
+		}
+	}
+
+	matches := Search(entries, regexp.MustCompile(`(?i)IN SHELLS`))
+	if len(matches) != 1 || matches[0].File != "b.yaml" || matches[0].Line != "in shells" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	testCases := []struct {
+		name     string
+		title    string
+		expected string
+	}{
+		{name: "Words", title: "Shell Pipes, Explained!", expected: "shell-pipes-explained"},
+		{name: "Non-ASCII letters", title: "パイプ とは", expected: "パイプ-とは"},
+		{name: "Symbols only", title: "?!", expected: ""},
+		{name: "Long title", title: "a very long title that keeps going on and on and on", expected: "a-very-long-title-that-keeps-going-on-an"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Slug(tc.title); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestTitledFileName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		file     string
+		title    string
+		expected string
+		ok       bool
+	}{
+		{name: "New file", file: "dir/20240301-101010.yaml", title: "Pipes", expected: "dir/20240301-101010-pipes.yaml", ok: true},
+		{name: "Retitled file", file: "dir/20240301-101010-pipes.yaml", title: "Shell pipes", expected: "dir/20240301-101010-shell-pipes.yaml", ok: true},
+		{name: "Imported file", file: "20240301-101010-chatgpt-1a2b3c4d.yaml", title: "Pipes", expected: "20240301-101010-pipes.yaml", ok: true},
+		{name: "Other file", file: "notes.yaml", title: "Pipes", expected: "notes.yaml", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := TitledFileName(tc.file, tc.title)
+			if got != filepath.FromSlash(tc.expected) || ok != tc.ok {
+				t.Errorf("expected %q %v, got %q %v", tc.expected, tc.ok, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package history
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// slugLength is the maximum number of characters of a slug.
+const slugLength = 40
+
+// Slug converts a title into a lowercase file name part, such as "shell-pipes-explained".
+func Slug(title string) string {
+	var sb strings.Builder
+	dash := false
+	n := 0
+	for _, r := range strings.ToLower(title) {
+		if n >= slugLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteRune('-')
+			dash = true
+		} else {
+			continue
+		}
+		n++
+	}
+	return strings.Trim(sb.String(), "-")
+}
+
+// TitledFileName returns the history file name for the title, keeping the timestamp the file name starts with.
+// Files not named by aski are not renamed, and ok is false.
+func TitledFileName(file string, title string) (string, bool) {
+	base := filepath.Base(file)
+	if len(base) < len(fileDateLayout) {
+		return file, false
+	}
+	stamp := base[:len(fileDateLayout)]
+	if _, err := time.Parse(fileDateLayout, stamp); err != nil {
+		return file, false
+	}
+
+	name := stamp + ".yaml"
+	if slug := Slug(title); slug != "" {
+		name = stamp + "-" + slug + ".yaml"
+	}
+	return filepath.Join(filepath.Dir(file), name), true
+}
+
+// MatchTitle returns the entries whose title contains text, ignoring case.
+func MatchTitle(entries []Entry, text string) []Entry {
+	text = strings.ToLower(text)
+	var result []Entry
+	for _, e := range entries {
+		if e.Title != "" && strings.Contains(strings.ToLower(e.Title), text) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	chatGPTConversation struct {
+		ID             string                 `json:"id"`
+		ConversationID string                 `json:"conversation_id"`
+		Title          string                 `json:"title"`
+		CreateTime     *float64               `json:"create_time"`
+		CurrentNode    string                 `json:"current_node"`
+		Mapping        map[string]chatGPTNode `json:"mapping"`
+	}
+
+	chatGPTNode struct {
+		ID       string          `json:"id"`
+		Parent   *string         `json:"parent"`
+		Children []string        `json:"children"`
+		Message  *chatGPTMessage `json:"message"`
+	}
+
+	chatGPTMessage struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		CreateTime *float64 `json:"create_time"`
+		Content    struct {
+			ContentType string        `json:"content_type"`
+			Parts       []interface{} `json:"parts"`
+			Text        string        `json:"text"`
+		} `json:"content"`
+		Metadata struct {
+			ModelSlug string `json:"model_slug"`
+		} `json:"metadata"`
+	}
+)
+
+// ParseChatGPT parses conversations.json of a ChatGPT data export.
+// The mapping in the export is already a tree with parent pointers, so all branches are preserved.
+func ParseChatGPT(data []byte) ([]Conversation, error) {
+	var raw []chatGPTConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse ChatGPT export: %w", err)
+	}
+
+	var result []Conversation
+	for _, c := range raw {
+		id := c.ConversationID
+		if id == "" {
+			id = c.ID
+		}
+
+		var nodes []node
+		system := ""
+		model := ""
+		for _, nodeID := range orderChatGPTNodes(c.Mapping) {
+			n := c.Mapping[nodeID]
+			parent := ""
+			if n.Parent != nil {
+				parent = *n.Parent
+			}
+
+			converted := node{id: nodeID, parent: parent}
+			if n.Message != nil {
+				converted.role = n.Message.Author.Role
+				converted.content = chatGPTContent(n.Message)
+				converted.createdAt = unixFloat(n.Message.CreateTime)
+
+				if converted.role == "system" && system == "" {
+					system = strings.TrimSpace(converted.content)
+				}
+				if converted.role == "assistant" && strings.HasPrefix(n.Message.Metadata.ModelSlug, "gpt") {
+					model = n.Message.Metadata.ModelSlug
+				}
+			}
+			nodes = append(nodes, converted)
+		}
+
+		messages := buildTree(nodes, c.CurrentNode)
+		if len(messages) == 0 {
+			continue
+		}
+
+		createdAt := unixFloat(c.CreateTime)
+		if createdAt.IsZero() {
+			createdAt = messages[0].CreatedAt
+		}
+
+		result = append(result, Conversation{
+			ID:        id,
+			Title:     c.Title,
+			Source:    SourceChatGPT,
+			CreatedAt: createdAt,
+			Model:     model,
+			System:    system,
+			Messages:  messages,
+		})
+	}
+
+	return result, nil
+}
+
+// orderChatGPTNodes returns node ids in tree order by following the children lists from the roots.
+// Map iteration order is random, so this keeps the converted file stable across imports.
+func orderChatGPTNodes(mapping map[string]chatGPTNode) []string {
+	var roots []string
+	for id, n := range mapping {
+		if n.Parent == nil || *n.Parent == "" {
+			roots = append(roots, id)
+		} else if _, ok := mapping[*n.Parent]; !ok {
+			roots = append(roots, id)
+		}
+	}
+	sort.Strings(roots)
+
+	var ordered []string
+	visited := map[string]bool{}
+	var walk func(id string)
+	walk = func(id string) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+		ordered = append(ordered, id)
+		for _, child := range mapping[id].Children {
+			if _, ok := mapping[child]; ok {
+				walk(child)
+			}
+		}
+	}
+
+	for _, root := range roots {
+		walk(root)
+	}
+
+	return ordered
+}
+
+func chatGPTContent(m *chatGPTMessage) string {
+	var parts []string
+	for _, p := range m.Content.Parts {
+		if s, ok := p.(string); ok && s != "" {
+			parts = append(parts, s)
+		}
+	}
+
+	if len(parts) == 0 {
+		return m.Content.Text
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+func unixFloat(t *float64) time.Time {
+	if t == nil || *t == 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(*t)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
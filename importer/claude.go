@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+	"time"
+)
+
+type (
+	claudeConversation struct {
+		UUID                   string          `json:"uuid"`
+		Name                   string          `json:"name"`
+		CreatedAt              time.Time       `json:"created_at"`
+		CurrentLeafMessageUUID string          `json:"current_leaf_message_uuid"`
+		ChatMessages           []claudeMessage `json:"chat_messages"`
+	}
+
+	claudeMessage struct {
+		UUID              string    `json:"uuid"`
+		ParentMessageUUID string    `json:"parent_message_uuid"`
+		Text              string    `json:"text"`
+		Sender            string    `json:"sender"`
+		CreatedAt         time.Time `json:"created_at"`
+		Content           []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Attachments []struct {
+			FileName         string `json:"file_name"`
+			ExtractedContent string `json:"extracted_content"`
+		} `json:"attachments"`
+	}
+)
+
+// ParseClaude parses conversations.json of a Claude.ai data export.
+// Older exports only contain the current branch as a flat list, newer ones link messages with parent_message_uuid.
+func ParseClaude(data []byte) ([]Conversation, error) {
+	var raw []claudeConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse Claude export: %w", err)
+	}
+
+	var result []Conversation
+	for _, c := range raw {
+		hasParents := false
+		for _, m := range c.ChatMessages {
+			if m.ParentMessageUUID != "" {
+				hasParents = true
+				break
+			}
+		}
+
+		var nodes []node
+		for i, m := range c.ChatMessages {
+			role := m.Sender
+			if role == "human" {
+				role = conv.ChatRoleUser
+			}
+
+			parent := ""
+			if hasParents {
+				parent = m.ParentMessageUUID
+			} else if i > 0 {
+				parent = c.ChatMessages[i-1].UUID
+			}
+
+			nodes = append(nodes, node{
+				id:        m.UUID,
+				parent:    parent,
+				role:      role,
+				content:   claudeContent(m),
+				createdAt: m.CreatedAt,
+			})
+		}
+
+		messages := buildTree(nodes, c.CurrentLeafMessageUUID)
+		if len(messages) == 0 {
+			continue
+		}
+
+		createdAt := c.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = messages[0].CreatedAt
+		}
+
+		result = append(result, Conversation{
+			ID:        c.UUID,
+			Title:     c.Name,
+			Source:    SourceClaude,
+			CreatedAt: createdAt,
+			Messages:  messages,
+		})
+	}
+
+	return result, nil
+}
+
+// claudeContent returns the message text. Attachments are appended in the same format as `aski -f`.
+func claudeContent(m claudeMessage) string {
+	text := m.Text
+	if text == "" {
+		var parts []string
+		for _, c := range m.Content {
+			if c.Type == "text" && c.Text != "" {
+				parts = append(parts, c.Text)
+			}
+		}
+		text = strings.Join(parts, "\n")
+	}
+
+	for _, a := range m.Attachments {
+		if a.ExtractedContent == "" {
+			continue
+		}
+		text += fmt.Sprintf("\n\nPath: `%s`\n ```\n%s```", a.FileName, a.ExtractedContent)
+	}
+
+	return text
+}
@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"strings"
+	"time"
+)
+
+const (
+	SourceChatGPT = "chatgpt"
+	SourceClaude  = "claude"
+)
+
+type (
+	// Conversation is a conversation converted from another client's data export.
+	Conversation struct {
+		ID        string
+		Title     string
+		Source    string
+		CreatedAt time.Time
+		// Model is empty when the export does not record it.
+		Model    string
+		System   string
+		Messages []conv.Message
+	}
+
+	// node is a message of the source tree before it is converted to conv.Message.
+	node struct {
+		id        string
+		parent    string
+		role      string
+		content   string
+		createdAt time.Time
+	}
+)
+
+// Parse converts a data export of the given source into conversations.
+func Parse(source string, data []byte) ([]Conversation, error) {
+	switch strings.ToLower(source) {
+	case SourceChatGPT:
+		return ParseChatGPT(data)
+	case SourceClaude:
+		return ParseClaude(data)
+	default:
+		return nil, fmt.Errorf("unknown import source: %s (chatgpt or claude)", source)
+	}
+}
+
+// FileName returns a history file name that is stable across repeated imports of the same conversation.
+func (c Conversation) FileName() string {
+	return fmt.Sprintf("%s-%s-%.8s.yaml", c.CreatedAt.Local().Format("20060102-150405"), c.Source, c.ID)
+}
+
+// buildTree converts nodes into conv.Messages. Sha1s are calculated as conv.Append does.
+// Nodes which are not user or assistant messages, or have no content, are dropped and their
+// children are attached to the nearest kept ancestor. current becomes HEAD, or the last message if empty.
+func buildTree(nodes []node, current string) []conv.Message {
+	known := map[string]bool{}
+	for _, n := range nodes {
+		known[n.id] = true
+	}
+
+	children := map[string][]node{}
+	var roots []node
+	for _, n := range nodes {
+		if n.parent == "" || !known[n.parent] {
+			roots = append(roots, n)
+			continue
+		}
+		children[n.parent] = append(children[n.parent], n)
+	}
+
+	messages := []conv.Message{}
+	shaOf := map[string]string{}
+	used := map[string]bool{}
+
+	var walk func(n node, parentSha1 string)
+	walk = func(n node, parentSha1 string) {
+		sha := parentSha1
+		if (n.role == conv.ChatRoleUser || n.role == conv.ChatRoleAssistant) && strings.TrimSpace(n.content) != "" {
+			sha = conv.CalculateSHA1([]string{n.role, n.content, parentSha1})
+			if used[sha] {
+				sha = conv.CalculateSHA1([]string{n.role, n.content, parentSha1, n.id})
+			}
+			used[sha] = true
+
+			messages = append(messages, conv.Message{
+				Sha1:       sha,
+				ParentSha1: parentSha1,
+				Role:       n.role,
+				Content:    n.content,
+				CreatedAt:  n.createdAt,
+			})
+		}
+		shaOf[n.id] = sha
+
+		for _, child := range children[n.id] {
+			walk(child, sha)
+		}
+	}
+
+	for _, root := range roots {
+		walk(root, "ROOT")
+	}
+
+	head := ""
+	if current != "" {
+		head = shaOf[current]
+	}
+	if head == "" && len(messages) > 0 {
+		head = messages[len(messages)-1].Sha1
+	}
+
+	for i := range messages {
+		messages[i].Head = messages[i].Sha1 == head
+	}
+
+	return messages
+}
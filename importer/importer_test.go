@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"testing"
+)
+
+const chatGPTExport = `[{
+  "title": "Greetings",
+  "create_time": 1700000000.5,
+  "current_node": "a2",
+  "conversation_id": "c0ffee00-1111-2222-3333-444455556666",
+  "mapping": {
+    "root": {"id": "root", "parent": null, "children": ["sys"], "message": null},
+    "sys": {"id": "sys", "parent": "root", "children": ["u1"], "message": {"author": {"role": "system"}, "create_time": null, "content": {"content_type": "text", "parts": [""]}}},
+    "u1": {"id": "u1", "parent": "sys", "children": ["a1", "a2"], "message": {"author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "text", "parts": ["hello"]}}},
+    "a1": {"id": "a1", "parent": "u1", "children": [], "message": {"author": {"role": "assistant"}, "create_time": 1700000002, "content": {"content_type": "text", "parts": ["hi"]}, "metadata": {"model_slug": "gpt-4"}}},
+    "a2": {"id": "a2", "parent": "u1", "children": [], "message": {"author": {"role": "assistant"}, "create_time": 1700000003, "content": {"content_type": "text", "parts": ["hello there"]}, "metadata": {"model_slug": "gpt-4"}}}
+  }
+}]`
+
+const claudeExport = `[{
+  "uuid": "5eed0000-1111-2222-3333-444455556666",
+  "name": "Files",
+  "created_at": "2024-03-01T10:00:00.000000+00:00",
+  "chat_messages": [
+    {"uuid": "m1", "text": "read this", "sender": "human", "created_at": "2024-03-01T10:00:01.000000+00:00",
+     "attachments": [{"file_name": "a.txt", "extracted_content": "abc"}]},
+    {"uuid": "m2", "text": "", "content": [{"type": "text", "text": "done"}], "sender": "assistant", "created_at": "2024-03-01T10:00:02.000000+00:00"}
+  ]
+}]`
+
+func TestParseChatGPT(t *testing.T) {
+	conversations, err := Parse("chatgpt", []byte(chatGPTExport))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conversations) != 1 {
+		t.Fatalf("Expected 1 conversation, but got %d", len(conversations))
+	}
+
+	c := conversations[0]
+	if c.Title != "Greetings" || c.Model != "gpt-4" {
+		t.Errorf("Unexpected title or model: %s, %s", c.Title, c.Model)
+	}
+	if len(c.Messages) != 3 {
+		t.Fatalf("Expected 3 messages, but got %d", len(c.Messages))
+	}
+
+	user := c.Messages[0]
+	if user.ParentSha1 != "ROOT" || user.CreatedAt.Unix() != 1700000001 {
+		t.Errorf("Expected the user message to be attached to ROOT with its timestamp, but got %+v", user)
+	}
+
+	for _, m := range c.Messages[1:] {
+		if m.ParentSha1 != user.Sha1 {
+			t.Errorf("Expected both answers to branch from the user message, but got parent %s", m.ParentSha1)
+		}
+		if m.Head != (m.Content == "hello there") {
+			t.Errorf("Expected current_node to be HEAD, but %q has Head=%v", m.Content, m.Head)
+		}
+	}
+
+	if c.FileName() != c.CreatedAt.Local().Format("20060102-150405")+"-chatgpt-c0ffee00.yaml" {
+		t.Errorf("Unexpected file name %s", c.FileName())
+	}
+}
+
+func TestParseClaude(t *testing.T) {
+	conversations, err := Parse("claude", []byte(claudeExport))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conversations) != 1 || len(conversations[0].Messages) != 2 {
+		t.Fatalf("Expected 1 conversation with 2 messages, but got %+v", conversations)
+	}
+
+	messages := conversations[0].Messages
+	if messages[0].Role != "user" || messages[0].Content != "read this\n\nPath: `a.txt`\n ```\nabc```" {
+		t.Errorf("Unexpected user message %+v", messages[0])
+	}
+	if messages[1].ParentSha1 != messages[0].Sha1 || messages[1].Content != "done" || !messages[1].Head {
+		t.Errorf("Unexpected assistant message %+v", messages[1])
+	}
+}
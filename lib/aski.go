@@ -2,8 +2,10 @@ package lib
 
 import (
 	"fmt"
+	"github.com/kznrluk/aski/command"
 	"github.com/kznrluk/aski/config"
 	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/crypt"
 	"github.com/kznrluk/aski/file"
 	"github.com/kznrluk/aski/session"
 	"github.com/spf13/cobra"
@@ -12,15 +14,42 @@ import (
 	"strings"
 )
 
+// RestorePick is the value of --restore when it is given without a value.
+// The value, if any, is then the positional argument, so both `-r` and `-r prefix` work.
+const RestorePick = "?"
+
+// SetConfigFlags passes the flags that override configuration values to config, for every subcommand.
+func SetConfigFlags(cmd *cobra.Command, args []string) {
+	for _, name := range config.FlagNames() {
+		if f := cmd.Flags().Lookup(name); f != nil && f.Changed {
+			if err := config.SetFlag(name, f.Value.String()); err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
 func Aski(cmd *cobra.Command, args []string) {
 	profileTarget, err := cmd.Flags().GetString("profile")
 	isRestMode, _ := cmd.Flags().GetBool("rest")
 	content, _ := cmd.Flags().GetString("content")
-	model, _ := cmd.Flags().GetString("model")
 	fileGlobs, _ := cmd.Flags().GetStringSlice("file")
 	restore, _ := cmd.Flags().GetString("restore")
+	vars, _ := cmd.Flags().GetStringArray("var")
+	restoring := restore != ""
+	if restore == RestorePick {
+		restore = ""
+		if len(args) > 0 {
+			restore = args[0]
+		}
+	} else if len(args) > 0 {
+		fmt.Printf("unknown command %q for aski\n", args[0])
+		os.Exit(1)
+	}
 	verbose, _ := cmd.Flags().GetBool("verbose")
 	session.SetVerbose(verbose)
+	session.SetRestMode(isRestMode)
 
 	fileInfo, _ := os.Stdin.Stat()
 	if (fileInfo.Mode() & os.ModeNamedPipe) != 0 {
@@ -31,40 +60,58 @@ func Aski(cmd *cobra.Command, args []string) {
 	if err != nil {
 		panic(err)
 	}
+	crypt.Configure(cfg)
+
+	cfg, err = cfg.ResolveAPIKeys()
+	if err != nil {
+		fmt.Printf("error reading API keys: %v\n", err)
+		os.Exit(1)
+	}
 
 	if cfg.OpenAIAPIKey == "" && cfg.AnthropicAPIKey == "" {
 		configPath := config.MustGetAskiDir()
-		fmt.Printf("No API key found. Please set your API key in %s/config.yaml\n", configPath)
+		fmt.Printf("No API key found. Please set %s or %s, or your API key in %s/config.yaml\n", config.EnvOpenAIAPIKey, config.EnvAnthropicAPIKey, configPath)
 		os.Exit(1)
 	}
 
-	prof, err := config.GetProfile(cfg, profileTarget)
+	// The path decides whether the templates of the profile are trusted, see config.TemplateOptions.
+	catalog := cfg.Catalog()
+	profilePath, err := config.FindProfile(cfg, profileTarget)
+	var prof config.Profile
+	if err == nil {
+		prof, err = config.LoadProfile(profilePath, catalog)
+	}
 	if err != nil {
 		fmt.Printf("error getting profile: %v\n. using default profile.", err)
 		prof = config.InitialProfile()
 	}
 
-	if model != "" {
-		prof.Model = model
+	prof, err = cfg.Overrides.Apply(prof)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
+	prof.Model = catalog.Resolve(prof.Model)
 
-	if strings.HasPrefix(prof.Model, "gpt") && cfg.OpenAIAPIKey == "" {
+	if catalog.Provider(prof.Model) == config.ProviderOpenAI && cfg.OpenAIAPIKey == "" {
 		fmt.Printf("OpenAIAPIKey is required for GPT model. Please set your API key in %s/config.yaml\n", config.MustGetAskiDir())
 		os.Exit(1)
 	}
 
-	if strings.HasPrefix(prof.Model, "claude") && cfg.AnthropicAPIKey == "" {
+	if catalog.Provider(prof.Model) == config.ProviderAnthropic && cfg.AnthropicAPIKey == "" {
 		fmt.Printf("AnthropicAPIKey is required for Claude model. Please set your API key in %s/config.yaml\n", config.MustGetAskiDir())
 		os.Exit(1)
 	}
 
 	var ctx conv.Conversation
-	if restore != "" {
-		load, fileName, err := ReadFileFromPWDAndHistoryDir(restore)
+	restorePath := ""
+	if restoring {
+		load, path, err := ReadFileFromPWDAndHistoryDir(restore)
 		if err != nil {
 			fmt.Printf("error reading restore file: %v\n", err)
 			os.Exit(1)
 		}
+		autoGC(cfg, path)
 
 		ctx, err = conv.FromYAML(load)
 		if err != nil {
@@ -73,16 +120,38 @@ func Aski(cmd *cobra.Command, args []string) {
 		}
 
 		if len(fileGlobs) != 0 {
-			// TODO: We should be able to renew file contents from the globs
-			fmt.Printf("WARN: File globs are ignored when loading restore.\n")
+			refreshFiles(ctx, fileGlobs, content == "" && !session.IsPipe())
 		}
 
 		if profileTarget != "" {
 			fmt.Printf("WARN: Profile is ignored when loading restore.\n")
 		}
+		if len(vars) != 0 {
+			fmt.Printf("WARN: --var is ignored when loading restore, the prompts were rendered when the conversation started.\n")
+		}
 
-		println("Restore conversations from " + fileName)
+		restorePath = path
+		println("Restore conversations from " + path)
 	} else {
+		autoGC(cfg, "")
+
+		// The rendered prompts are stored in the conversation, so restoring it gives the same prompts.
+		templateVars, err := config.ParseVars(vars)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+		prof, err = config.RenderProfile(prof, config.TemplateOptions{
+			Vars:            templateVars,
+			AllowShell:      cfg.TemplateShell,
+			Origin:          profilePath,
+			TrustedProjects: cfg.TrustedProjects,
+		})
+		if err != nil {
+			fmt.Printf("error rendering profile: %v\n", err)
+			os.Exit(1)
+		}
+
 		ctx = conv.NewConversation(prof)
 		ctx.SetSystem(prof.SystemContext)
 
@@ -92,7 +161,7 @@ func Aski(cmd *cobra.Command, args []string) {
 				if content == "" && !session.IsPipe() {
 					fmt.Printf("Append File: %s\n", f.Name)
 				}
-				ctx.Append(conv.ChatRoleUser, fmt.Sprintf("Path: `%s`\n ```\n%s```", f.Path, f.Contents))
+				ctx.AppendAttachment(f.Path, f.Contents, f.ModTime)
 			}
 		}
 
@@ -128,6 +197,32 @@ func Aski(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 	} else {
-		StartDialog(cfg, ctx, isRestMode, restore != "")
+		StartDialog(cfg, ctx, isRestMode, restorePath)
+	}
+}
+
+// refreshFiles swaps in the current contents of the files already attached to the restored conversation,
+// and attaches the files matching the globs that are not attached yet.
+func refreshFiles(ctx conv.Conversation, fileGlobs []string, verbose bool) {
+	changed, err := command.RefreshAttachments(ctx, false)
+	if err != nil {
+		fmt.Printf("error refreshing files: %v\n", err)
+		os.Exit(1)
+	}
+	if verbose {
+		for _, path := range changed {
+			fmt.Printf("Refresh File: %s\n", path)
+		}
+	}
+
+	attached := ctx.AttachedFilesFromHead()
+	for _, f := range file.GetFileContents(fileGlobs) {
+		if _, ok := attached[conv.AttachmentPath(f.Path)]; ok {
+			continue
+		}
+		if verbose {
+			fmt.Printf("Append File: %s\n", f.Name)
+		}
+		ctx.AppendAttachment(f.Path, f.Contents, f.ModTime)
 	}
 }
@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/crypt"
+	"github.com/kznrluk/aski/schema"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+func ConfigShow(cmd *cobra.Command, args []string) {
+	sources, _ := cmd.Flags().GetBool("sources")
+
+	cfg, origins, err := config.GetEffectiveConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if sources {
+		keys, err := cfg.APIKeySources()
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+
+		_, _ = fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, k := range keys {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", k.Name, config.MaskAPIKey(k.Key), k.Source)
+		}
+		_ = w.Flush()
+		fmt.Printf("\nPrecedence: %s / %s, APIKeyCommand, then config.yaml (plain or file:path).\n", config.EnvOpenAIAPIKey, config.EnvAnthropicAPIKey)
+		return
+	}
+
+	// References to files are not secret, the keys themselves are.
+	for _, key := range []*string{&cfg.OpenAIAPIKey, &cfg.AnthropicAPIKey} {
+		if !strings.HasPrefix(*key, config.FileReferencePrefix) {
+			*key = config.MaskAPIKey(*key)
+		}
+	}
+
+	values, err := config.Values(cfg, origins)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	_, _ = fmt.Fprintln(w, "KEY\tVALUE\tORIGIN")
+	for _, v := range values {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", v.Key, oneLine(v.Value, firstMessageWidth), v.Origin)
+	}
+	_ = w.Flush()
+}
+
+// validateTarget is a file checked by aski config validate. kind is one of schema.Kinds.
+type validateTarget struct {
+	path string
+	kind string
+}
+
+func ConfigValidate(cmd *cobra.Command, args []string) {
+	kind, _ := cmd.Flags().GetString("type")
+	if kind != "" {
+		if _, err := schema.For(kind); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var targets []validateTarget
+	if len(args) > 0 {
+		if kind == "" {
+			kind = detectKind(args[0])
+		}
+		targets = append(targets, validateTarget{path: args[0], kind: kind})
+	} else {
+		targets = validateTargets()
+	}
+
+	catalog := config.LoadCatalog()
+	failed, skipped := 0, 0
+	for _, t := range targets {
+		errs, skip := validateFile(t, catalog)
+		switch {
+		case skip != "":
+			skipped++
+			fmt.Printf("skip %s: %s\n", t.path, skip)
+		case len(errs) > 0:
+			failed++
+			printValidation(t.path, errs)
+		case t.kind != "history" || len(args) > 0:
+			// History directories may hold many files, only their failures are listed.
+			printValidation(t.path, nil)
+		}
+	}
+
+	fmt.Printf("Checked %d file(s): %d invalid, %d skipped.\n", len(targets), failed, skipped)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// validateTargets returns the config files, the profiles and the history files of the aski directory and the project.
+func validateTargets() []validateTarget {
+	var targets []validateTarget
+	for _, dir := range []string{config.MustGetAskiDir(), config.GetProjectDir()} {
+		path := filepath.Join(dir, "config.yaml")
+		if _, err := os.Stat(path); dir != "" && err == nil {
+			targets = append(targets, validateTarget{path: path, kind: "config"})
+		}
+	}
+
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range profiles {
+		targets = append(targets, validateTarget{path: p.Path, kind: "profile"})
+	}
+
+	for _, dir := range config.HistoryDirs() {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		for _, f := range files {
+			targets = append(targets, validateTarget{path: f, kind: "history"})
+		}
+	}
+	return targets
+}
+
+// detectKind guesses the kind of a file from its name and location.
+func detectKind(path string) string {
+	if filepath.Base(path) == "config.yaml" {
+		return "config"
+	}
+	if filepath.Base(filepath.Dir(path)) == "history" {
+		return "history"
+	}
+	if data, err := os.ReadFile(path); err == nil && crypt.IsEncrypted(data) {
+		return "history"
+	}
+	return "profile"
+}
+
+// validateFile returns the problems of the file, or why it was skipped. Profiles resolve their model in catalog.
+func validateFile(t validateTarget, catalog config.Catalog) ([]error, string) {
+	switch t.kind {
+	case "config":
+		return config.ValidateConfigFile(t.path), ""
+	case "profile":
+		return config.ValidateProfileFile(t.path, catalog), ""
+	}
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return []error{err}, ""
+	}
+	if crypt.IsEncrypted(data) {
+		// Asking for the passphrase for every file would make the command unusable in scripts.
+		if !crypt.PassphraseAvailable() {
+			return nil, "encrypted, set ASKI_HISTORY_KEY or HistoryKeyFile to check it"
+		}
+		if data, err = crypt.Open(data); err != nil {
+			return []error{err}, ""
+		}
+	}
+	return conv.Validate(data), ""
+}
+
+func printValidation(path string, errs []error) {
+	if len(errs) == 0 {
+		fmt.Printf("ok   %s\n", path)
+		return
+	}
+
+	fmt.Printf("FAIL %s\n", path)
+	for _, err := range errs {
+		for _, line := range strings.Split(strings.TrimRight(err.Error(), "\n"), "\n") {
+			fmt.Printf("     %s\n", line)
+		}
+	}
+}
+
+func ConfigSchema(cmd *cobra.Command, args []string) {
+	s, err := schema.For(args[0])
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := s.JSON()
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	_, _ = os.Stdout.Write(data)
+}
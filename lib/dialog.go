@@ -9,6 +9,9 @@ import (
 	"github.com/kznrluk/aski/command"
 	"github.com/kznrluk/aski/config"
 	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/crypt"
+	"github.com/kznrluk/aski/history"
+	"github.com/kznrluk/aski/util"
 	"github.com/mattn/go-colorable"
 	"io"
 
@@ -22,7 +25,8 @@ import (
 	"time"
 )
 
-func StartDialog(cfg config.Config, cv conv.Conversation, isRestMode bool, restored bool) {
+// StartDialog runs the interactive session. A restored conversation is saved back to restorePath.
+func StartDialog(cfg config.Config, cv conv.Conversation, isRestMode bool, restorePath string) {
 	if isRestMode {
 		fmt.Printf("REST Mode \n")
 	}
@@ -43,9 +47,60 @@ func StartDialog(cfg config.Config, cv conv.Conversation, isRestMode bool, resto
 	editor.Init()
 	fmt.Printf("Profile: %s, Model: %s \n", profile.ProfileName, profile.Model)
 
+	catalog := cfg.Catalog()
 	cli := chat.ProvideChat(profile.Model, cfg)
+	// model is the model cli was created for. :model may switch to a model of another provider.
+	model := profile.Model
+
+	// A new conversation is saved once the first answer arrives, and then after every turn to the same file.
+	first := restorePath == ""
+	historyPath := restorePath
+	// snapshot is the history file as last read or saved, to detect another session saving to the same file.
+	var snapshot util.Snapshot
+	if restorePath != "" {
+		var err error
+		if snapshot, err = util.TakeSnapshot(restorePath); err != nil {
+			fmt.Printf("error reading %s: %v\n", restorePath, err)
+		}
+	}
+	// save writes the conversation to historyPath if it is to be saved, and reports whether it was saved.
+	// Errors are printed, as the conversation continues regardless.
+	save := func() bool {
+		if !profile.AutoSave || first {
+			return false
+		}
+		if historyPath == "" {
+			historyPath = unusedHistoryPath(newHistoryPath())
+		}
+		saved, err := SaveHistoryFile(historyPath, cv, snapshot)
+		if errors.Is(err, util.ErrConflict) {
+			// Keep the other session's version, and continue this session in a file of its own.
+			conflicted := historyPath
+			historyPath = unusedHistoryPath(historyPath)
+			saved, err = SaveHistoryFile(historyPath, cv, util.Snapshot{})
+			if err == nil {
+				fmt.Printf("\n%s was changed by another aski session. This conversation is saved to %s instead.\n", conflicted, historyPath)
+			}
+		}
+		if err != nil {
+			fmt.Printf("\nerror saving conversation: %v\n", err)
+			return false
+		}
+		snapshot = saved
+		// The file name would reveal the title of an encrypted conversation.
+		if cv.GetTitle() != "" && !crypt.Enabled() {
+			historyPath = renameHistoryFile(historyPath, cv.GetTitle())
+		}
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			save()
+			panic(r)
+		}
+	}()
 
-	first := !restored
 	for {
 		fmt.Printf("\n")
 		editor.PromptWriter = func(w io.Writer) (int, error) {
@@ -55,15 +110,9 @@ func StartDialog(cfg config.Config, cv conv.Conversation, isRestMode bool, resto
 		input, err, interrupt := getInput(editor)
 
 		history.Add(input)
-		if interrupt || strings.HasPrefix(input, ":ex") {
-			if profile.AutoSave && !first {
-				fmt.Printf("\nSaving conversation... ")
-				fn, err := saveConversation(cv)
-				if err != nil {
-					fmt.Printf("\n error saving conversation: %v\n", err)
-					os.Exit(1)
-				}
-				fmt.Println(fn)
+		if interrupt || isExitCommand(input) {
+			if save() {
+				fmt.Printf("\nConversation saved to %s\n", historyPath)
 			}
 			os.Exit(0)
 		}
@@ -77,15 +126,28 @@ func StartDialog(cfg config.Config, cv conv.Conversation, isRestMode bool, resto
 			continue
 		}
 
-		cv, cont, commandErr := appendMessage(input, cv)
+		cv, cont, commandErr := appendMessage(input, cv, cli)
 		if commandErr != nil {
 			fmt.Printf("error: %v\n", commandErr)
 		}
 
 		if !cont {
+			save()
 			continue
 		}
 
+		if m := cv.GetProfile().Model; m != model {
+			cli = chat.ProvideChat(m, cfg)
+			model = m
+		}
+
+		if cv.GetProfile().Summarize && command.NeedsCompaction(cv, catalog) {
+			fmt.Printf("\nThe conversation is close to the context limit. ")
+			if _, err := command.Compact(cv, cli, command.DefaultKeepMessages); err != nil {
+				fmt.Printf("error: %v\n", err)
+			}
+		}
+
 		last := cv.Last()
 		yellow := color.New(color.FgHiYellow).SprintFunc()
 		fmt.Print(yellow(fmt.Sprintf("\n%s -> [%.*s] \n", last.Role, 6, last.ParentSha1)))
@@ -111,9 +173,13 @@ func StartDialog(cfg config.Config, cv conv.Conversation, isRestMode bool, resto
 
 		msg := cv.Append(conv.ChatRoleAssistant, data)
 		fmt.Print(yellow(fmt.Sprintf(" [%.*s]\n", 6, msg.Sha1)))
-		if first {
-			first = false
+		if first && profile.AutoTitle && cv.GetTitle() == "" {
+			if _, err := command.GenerateTitle(cv); err != nil {
+				fmt.Printf("%v\n", err)
+			}
 		}
+		first = false
+		save()
 	}
 }
 
@@ -154,38 +220,47 @@ func getInput(reader *readline.Editor) (string, error, bool) {
 	}
 }
 
-func saveConversation(conv conv.Conversation) (string, error) {
-	t := time.Now()
+// newHistoryPath returns a path in the history directory named after the current time.
+func newHistoryPath() string {
+	historyDir := config.MustGetHistoryDir()
+	if err := os.MkdirAll(historyDir, 0700); err != nil {
+		fmt.Printf("error creating history directory: %v\n", err)
+	}
 
-	filename := fmt.Sprintf("%s.yaml", t.Format("20060102-150405"))
+	return filepath.Join(historyDir, fmt.Sprintf("%s.yaml", time.Now().Format("20060102-150405")))
+}
 
-	homeDir, err := config.GetHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("error getting home directory: %v", err)
+// renameHistoryFile renames a history file saved by aski after the title, and returns the new path.
+// Files outside the history directories are left as is.
+func renameHistoryFile(path string, title string) string {
+	inHistoryDir := false
+	for _, dir := range config.HistoryDirs() {
+		if filepath.Clean(filepath.Dir(path)) == filepath.Clean(dir) {
+			inHistoryDir = true
+		}
 	}
-
-	configDir := filepath.Join(homeDir, ".aski", "history")
-	if err := os.MkdirAll(configDir, 0700); err != nil {
-		return filename, err
+	if !inHistoryDir {
+		return path
 	}
 
-	yamlString, err := conv.ToYAML()
-	if err != nil {
-		return filename, err
+	renamed, ok := history.TitledFileName(path, title)
+	if !ok || renamed == path {
+		return path
 	}
-
-	filePath := filepath.Join(configDir, filename)
-	err = os.WriteFile(filePath, yamlString, 0600)
-	if err != nil {
-		return filename, err
+	if _, err := os.Stat(renamed); err == nil {
+		return path
 	}
 
-	return filename, nil
+	if err := os.Rename(path, renamed); err != nil {
+		fmt.Printf("\nerror renaming conversation: %v\n", err)
+		return path
+	}
+	return renamed
 }
 
-func appendMessage(input string, ctx conv.Conversation) (conv.Conversation, bool, error) {
+func appendMessage(input string, ctx conv.Conversation, cli chat.Chat) (conv.Conversation, bool, error) {
 	if len(input) > 0 && input[0] == ':' && input != ":exit" {
-		ctx, cont, commandErr := command.Parse(input, ctx)
+		ctx, cont, commandErr := command.Parse(input, ctx, cli)
 		if commandErr != nil {
 			return ctx, false, commandErr
 		}
@@ -199,6 +274,11 @@ func appendMessage(input string, ctx conv.Conversation) (conv.Conversation, bool
 	return ctx, true, nil
 }
 
+// isExitCommand accepts :ex, :exi and :exit. Other commands starting with :ex, such as :export, are not exits.
+func isExitCommand(input string) bool {
+	return len(input) >= 3 && strings.HasPrefix(":exit", input)
+}
+
 func showPendingHeader(role string, to conv.Message) {
 	yellow := color.New(color.FgHiYellow).SprintFunc()
 	fmt.Print(yellow(fmt.Sprintf("\n%s -> [%.*s]", role, 6, to.Sha1)))
@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/export"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+func Export(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("format")
+	branch, _ := cmd.Flags().GetString("branch")
+	all, _ := cmd.Flags().GetBool("all")
+	output, _ := cmd.Flags().GetString("output")
+
+	if branch != "" && all {
+		fmt.Printf("--branch and --all cannot be used together\n")
+		os.Exit(1)
+	}
+
+	load, _, err := ReadFileFromPWDAndHistoryDir(args[0])
+	if err != nil {
+		fmt.Printf("error reading history file: %v\n", err)
+		os.Exit(1)
+	}
+
+	cv, err := conv.FromYAML(load)
+	if err != nil {
+		fmt.Printf("error parsing history file: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := export.Render(cv, format, export.Options{Branch: branch, All: all})
+	if err != nil {
+		fmt.Printf("error exporting: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "" {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+
+	if err := os.WriteFile(output, data, 0600); err != nil {
+		fmt.Printf("error writing %s: %v\n", output, err)
+		os.Exit(1)
+	}
+}
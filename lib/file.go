@@ -3,32 +3,171 @@ package lib
 import (
 	"fmt"
 	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/crypt"
+	"github.com/kznrluk/aski/history"
+	"github.com/kznrluk/aski/util"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// ReadFileFromPWDAndHistoryDir reads the history file whose name starts with partialFilename, and returns it with its path.
+// If no file name matches, the conversations whose title contains partialFilename are searched.
+// When several conversations match, or partialFilename is empty, the user picks one.
 func ReadFileFromPWDAndHistoryDir(partialFilename string) ([]byte, string, error) {
-	str := config.MustGetHistoryDir()
+	path, err := findHistoryFile(partialFilename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read file: %s", err)
+	}
+
+	b, err = crypt.Open(b)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return b, path, nil
+}
 
-	dirsToSearch := []string{".", filepath.Join(str, "")}
+func findHistoryFile(partialFilename string) (string, error) {
+	historyDirs := config.HistoryDirs()
 
+	// Without a prefix, only the history directories are listed, as pwd may contain anything.
+	dirsToSearch := historyDirs
+	if partialFilename != "" {
+		dirsToSearch = append([]string{"."}, historyDirs...)
+	}
+
+	var paths []string
+	var readErr error
+	seen := map[string]bool{}
 	for _, dir := range dirsToSearch {
 		files, err := os.ReadDir(dir)
 		if err != nil {
-			return nil, "", err
+			// An unreadable pwd should not prevent restoring from the history directory.
+			readErr = err
+			continue
 		}
 
 		for _, file := range files {
-			if !file.IsDir() && strings.HasPrefix(file.Name(), partialFilename) {
-				b, err := os.ReadFile(filepath.Join(dir, file.Name()))
-				if err != nil {
-					return nil, "", fmt.Errorf("cannot read file: %s", err)
+			name := file.Name()
+			if file.IsDir() || !strings.HasPrefix(name, partialFilename) {
+				continue
+			}
+			if partialFilename == "" && !strings.HasSuffix(name, ".yaml") {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if abs, err := filepath.Abs(path); err == nil {
+				if seen[abs] {
+					continue
 				}
-				return b, file.Name(), err
+				seen[abs] = true
 			}
+
+			if name == partialFilename || name == partialFilename+".yaml" {
+				return path, nil
+			}
+			paths = append(paths, path)
 		}
 	}
 
-	return nil, "", fmt.Errorf("file not found")
+	if len(paths) == 1 {
+		return paths[0], nil
+	}
+
+	var entries []history.Entry
+	if len(paths) > 1 {
+		for _, path := range paths {
+			if e, err := history.LoadFile(path); err == nil {
+				entries = append(entries, e)
+			}
+		}
+		history.Sort(entries)
+	} else if partialFilename != "" {
+		// Fall back to the titles of the conversations in the history directories.
+		var all []history.Entry
+		for _, dir := range historyDirs {
+			if loaded, _, err := history.Load(dir); err == nil {
+				all = append(all, loaded...)
+			}
+		}
+		history.Sort(all)
+		entries = history.MatchTitle(all, partialFilename)
+	}
+
+	switch len(entries) {
+	case 0:
+		if len(paths) > 0 {
+			// None of them could be parsed. Return one so that the caller reports why.
+			return paths[0], nil
+		}
+		if readErr != nil && len(seen) == 0 {
+			return "", fmt.Errorf("file not found: %v", readErr)
+		}
+		return "", fmt.Errorf("file not found")
+	case 1:
+		return entries[0].Path, nil
+	}
+
+	e, err := pickHistory(entries)
+	if err != nil {
+		return "", err
+	}
+	return e.Path, nil
+}
+
+// WriteHistoryFile saves the conversation as filename in the history directory, replacing any file of that name.
+func WriteHistoryFile(filename string, cv conv.Conversation) (string, error) {
+	historyDir := config.MustGetHistoryDir()
+	if err := os.MkdirAll(historyDir, 0700); err != nil {
+		return filename, err
+	}
+
+	path := filepath.Join(historyDir, filename)
+	current, err := util.TakeSnapshot(path)
+	if err != nil {
+		return filename, err
+	}
+	if _, err := SaveHistoryFile(path, cv, current); err != nil {
+		return filename, err
+	}
+
+	return filename, nil
+}
+
+// SaveHistoryFile replaces the file at path with the conversation atomically, and returns the snapshot of the new file.
+// It fails with util.ErrConflict if the file does not match expected, which is the snapshot from when it was read or last saved.
+// The file is encrypted when EncryptHistory is set in the config.
+func SaveHistoryFile(path string, cv conv.Conversation, expected util.Snapshot) (util.Snapshot, error) {
+	yamlString, err := cv.ToYAML()
+	if err != nil {
+		return util.Snapshot{}, err
+	}
+
+	if crypt.Enabled() {
+		yamlString, err = crypt.Seal(yamlString)
+		if err != nil {
+			return util.Snapshot{}, err
+		}
+	}
+
+	return util.WriteFileChecked(path, yamlString, 0600, expected)
+}
+
+// unusedHistoryPath returns path, or path with a number appended if a file of that name exists.
+func unusedHistoryPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
 }
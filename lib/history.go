@@ -0,0 +1,347 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/crypt"
+	"github.com/kznrluk/aski/export"
+	"github.com/kznrluk/aski/history"
+	"github.com/kznrluk/aski/util"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	dateFlagLayout = "2006-01-02"
+	// firstMessageWidth is the number of characters of the first message shown in the table.
+	firstMessageWidth = 50
+)
+
+func HistoryList(cmd *cobra.Command, args []string) {
+	profile, _ := cmd.Flags().GetString("profile")
+	model, _ := cmd.Flags().GetString("model")
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	filter := history.Filter{Profile: profile, Model: model}
+	if since != "" {
+		t, err := time.ParseInLocation(dateFlagLayout, since, time.Local)
+		if err != nil {
+			fmt.Printf("invalid --since date, expected YYYY-MM-DD: %s\n", since)
+			os.Exit(1)
+		}
+		filter.Since = t
+	}
+	if until != "" {
+		t, err := time.ParseInLocation(dateFlagLayout, until, time.Local)
+		if err != nil {
+			fmt.Printf("invalid --until date, expected YYYY-MM-DD: %s\n", until)
+			os.Exit(1)
+		}
+		// Include the whole day.
+		filter.Until = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	entries := history.Apply(loadHistory(), filter)
+
+	if asJSON {
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		printJSON(entries)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "DATE\tFILE\tPROFILE\tMODEL\tMESSAGES\tBRANCHES\tTITLE\tFIRST MESSAGE")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			e.Date.Local().Format("2006-01-02 15:04"), e.File, e.Profile, e.Model, e.Messages, e.Branches, e.Title, oneLine(e.FirstMessage, firstMessageWidth))
+	}
+	_ = w.Flush()
+}
+
+func HistorySearch(cmd *cobra.Command, args []string) {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+
+	pattern := args[0]
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Printf("invalid regular expression: %v\n", err)
+		os.Exit(1)
+	}
+
+	matches := history.Search(loadHistory(), re)
+
+	if asJSON {
+		if matches == nil {
+			matches = []history.Match{}
+		}
+		printJSON(matches)
+		return
+	}
+
+	for _, m := range matches {
+		fmt.Printf("%s [%.*s] %s: %s\n", m.File, 6, m.Sha1, m.Role, strings.TrimSpace(m.Line))
+	}
+}
+
+func HistoryShow(cmd *cobra.Command, args []string) {
+	all, _ := cmd.Flags().GetBool("all")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	load, path, err := ReadFileFromPWDAndHistoryDir(args[0])
+	if err != nil {
+		fmt.Printf("error reading history file: %v\n", err)
+		os.Exit(1)
+	}
+
+	cv, err := conv.FromYAML(load)
+	if err != nil {
+		fmt.Printf("error parsing history file: %v\n", err)
+		os.Exit(1)
+	}
+
+	format := export.FormatMarkdown
+	if asJSON {
+		format = export.FormatJSON
+	} else {
+		e := history.NewEntry(filepath.Base(path), time.Time{}, cv)
+		fmt.Printf("File:     %s\nTitle:    %s\nDate:     %s\nProfile:  %s\nModel:    %s\nMessages: %d\nBranches: %d\n\n",
+			e.File, e.Title, e.Date.Local().Format("2006-01-02 15:04"), e.Profile, e.Model, e.Messages, e.Branches)
+	}
+
+	data, err := export.Render(cv, format, export.Options{All: all})
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	_, _ = os.Stdout.Write(data)
+}
+
+// loadHistory reads the conversations of every history directory, see config.HistoryDirs.
+func loadHistory() []history.Entry {
+	var entries []history.Entry
+	for _, dir := range config.HistoryDirs() {
+		entries = append(entries, loadHistoryDir(dir)...)
+	}
+	history.Sort(entries)
+	return entries
+}
+
+func loadHistoryDir(dir string) []history.Entry {
+	entries, skipped, err := history.Load(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		fmt.Printf("error reading history: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, err := range skipped {
+		fmt.Fprintf(os.Stderr, "WARN: skipping %v\n", err)
+	}
+	return entries
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// oneLine flattens text into a single line of at most width characters.
+func oneLine(text string, width int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) > width {
+		return string(runes[:width-3]) + "..."
+	}
+	return string(runes)
+}
+
+func HistoryEncrypt(cmd *cobra.Command, args []string) {
+	all, _ := cmd.Flags().GetBool("all")
+
+	if all == (len(args) != 0) {
+		fmt.Printf("specify history files or --all\n")
+		os.Exit(1)
+	}
+
+	var paths []string
+	if all {
+		for _, historyDir := range config.HistoryDirs() {
+			files, err := os.ReadDir(historyDir)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				fmt.Printf("error reading history: %v\n", err)
+				os.Exit(1)
+			}
+			for _, file := range files {
+				if !file.IsDir() && strings.HasSuffix(file.Name(), ".yaml") {
+					paths = append(paths, filepath.Join(historyDir, file.Name()))
+				}
+			}
+		}
+	} else {
+		for _, arg := range args {
+			path, err := findHistoryFile(arg)
+			if err != nil {
+				fmt.Printf("error finding %s: %v\n", arg, err)
+				os.Exit(1)
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	encrypted := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("error reading %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		if crypt.IsEncrypted(data) {
+			continue
+		}
+		if _, err := conv.FromYAML(data); err != nil {
+			fmt.Printf("WARN: skipping %s, not a conversation: %v\n", filepath.Base(path), err)
+			continue
+		}
+
+		sealed, err := crypt.Seal(data)
+		if err != nil {
+			fmt.Printf("error encrypting %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		if _, err := util.WriteFileChecked(path, sealed, 0600, util.SnapshotOf(data)); err != nil {
+			fmt.Printf("error writing %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Encrypted: %s\n", filepath.Base(path))
+		encrypted++
+	}
+
+	fmt.Printf("Encrypted %d file(s).\n", encrypted)
+	if !crypt.Enabled() {
+		fmt.Printf("Set EncryptHistory: true in %s to encrypt new conversations.\n", filepath.Join(config.MustGetAskiDir(), "config.yaml"))
+	}
+}
+
+func HistoryGC(cmd *cobra.Command, args []string) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.HistoryRetention.IsZero() {
+		fmt.Printf("No retention limits. Set HistoryRetention in %s.\n", filepath.Join(config.MustGetAskiDir(), "config.yaml"))
+		return
+	}
+
+	// The limits apply to each history directory, so the conversations of a project do not push out the others.
+	var expired []history.Expired
+	for _, dir := range config.HistoryDirs() {
+		e, err := history.Collect(loadHistoryDir(dir), cfg.HistoryRetention, time.Now())
+		if err != nil {
+			fmt.Printf("error in HistoryRetention: %v\n", err)
+			os.Exit(1)
+		}
+		expired = append(expired, e...)
+	}
+
+	freed := int64(0)
+	deleted := 0
+	for _, e := range expired {
+		if !dryRun {
+			if err := os.Remove(e.Path); err != nil {
+				fmt.Printf("error deleting %s: %v\n", e.File, err)
+				continue
+			}
+		}
+		fmt.Printf("%s  %s  (%s)\n", e.Date.Local().Format("2006-01-02 15:04"), e.File, e.Reason)
+		freed += e.Size
+		deleted++
+	}
+
+	if dryRun {
+		fmt.Printf("Would delete %d conversation(s), %s.\n", deleted, formatSize(freed))
+		return
+	}
+	fmt.Printf("Deleted %d conversation(s), %s.\n", deleted, formatSize(freed))
+}
+
+// autoGC deletes the conversations exceeding HistoryRetention when Auto is set, except keepPath.
+// Encrypted files are left alone unless they can be read without a prompt.
+func autoGC(cfg config.Config, keepPath string) {
+	if !cfg.HistoryRetention.Auto {
+		return
+	}
+
+	load := history.LoadUnencrypted
+	if crypt.PassphraseAvailable() {
+		load = history.Load
+	}
+	var expired []history.Expired
+	for _, dir := range config.HistoryDirs() {
+		entries, _, err := load(dir)
+		if err != nil {
+			continue
+		}
+
+		e, err := history.Collect(entries, cfg.HistoryRetention, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARN: history gc skipped, error in HistoryRetention: %v\n", err)
+			return
+		}
+		expired = append(expired, e...)
+	}
+
+	deleted := 0
+	for _, e := range expired {
+		if keepPath != "" && filepath.Clean(e.Path) == filepath.Clean(keepPath) {
+			continue
+		}
+		if err := os.Remove(e.Path); err != nil {
+			fmt.Fprintf(os.Stderr, "WARN: error deleting %s: %v\n", e.File, err)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		fmt.Fprintf(os.Stderr, "Deleted %d old conversation(s) from history.\n", deleted)
+	}
+}
+
+// formatSize formats a number of bytes with a binary unit, such as "1.5 MB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/crypt"
+	"github.com/kznrluk/aski/importer"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+func Import(cmd *cobra.Command, args []string) {
+	source, path := args[0], args[1]
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("error reading %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	conversations, err := importer.Parse(source, data)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+	crypt.Configure(cfg)
+
+	prof, err := config.GetProfile(cfg, "")
+	if err != nil {
+		fmt.Printf("error getting profile: %v\n. using default profile.", err)
+		prof = config.InitialProfile()
+	}
+
+	for _, c := range conversations {
+		p := prof
+		if c.Model != "" {
+			p.Model = c.Model
+		}
+
+		system := c.System
+		if system == "" {
+			system = prof.SystemContext
+		}
+
+		cv := conv.FromMessages(p, system, c.Messages)
+		cv.SetTitle(c.Title)
+		fn, err := WriteHistoryFile(c.FileName(), cv)
+		if err != nil {
+			fmt.Printf("error saving %s: %v\n", c.Title, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s  %s (%d messages)\n", fn, c.Title, len(c.Messages))
+	}
+
+	fmt.Printf("Imported %d conversation(s) into %s\n", len(conversations), config.MustGetHistoryDir())
+}
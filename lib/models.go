@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/kznrluk/aski/config"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func Models(cmd *cobra.Command, args []string) {
+	provider, _ := cmd.Flags().GetString("provider")
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+	catalog := cfg.Catalog()
+
+	// The model of the current profile, as a conversation started now would use it.
+	current := ""
+	if prof, err := config.GetProfile(cfg, ""); err == nil {
+		if prof, err = cfg.Overrides.Apply(prof); err == nil {
+			current = catalog.Resolve(prof.Model)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "\tMODEL\tALIASES\tPROVIDER\tCONTEXT\tMAX OUTPUT\tINPUT\tINPUT $/1M\tOUTPUT $/1M")
+	for _, m := range catalog {
+		if provider != "" && m.Provider != provider {
+			continue
+		}
+		mark := ""
+		if m.ID == current {
+			mark = "*"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", mark, m.ID, strings.Join(m.Aliases, ","), m.Provider,
+			formatTokens(m.ContextWindow), formatTokens(m.MaxOutputTokens), strings.Join(m.Modalities, ","),
+			formatPrice(m.InputPrice), formatPrice(m.OutputPrice))
+	}
+	_ = w.Flush()
+}
+
+// formatTokens formats a number of tokens such as 128000 as 128k, or - when unknown.
+func formatTokens(n int) string {
+	switch {
+	case n == 0:
+		return "-"
+	case n%1000 == 0:
+		return fmt.Sprintf("%dk", n/1000)
+	}
+	return strconv.Itoa(n)
+}
+
+func formatPrice(p float64) string {
+	if p == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
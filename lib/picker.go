@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"fmt"
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kznrluk/aski/conv"
+	"github.com/kznrluk/aski/history"
+	"github.com/kznrluk/aski/session"
+	"github.com/kznrluk/aski/util"
+	"golang.org/x/term"
+	"os"
+)
+
+const (
+	// pickerTitleWidth and pickerPreviewWidth are the number of characters shown for each conversation.
+	pickerTitleWidth   = 50
+	pickerPreviewWidth = 60
+	pickerPageSize     = 15
+)
+
+// pickHistory lets the user choose one of the entries, which are sorted newest first.
+// Without a terminal, the newest entry is chosen.
+func pickHistory(entries []history.Entry) (history.Entry, error) {
+	// The picker needs a terminal on both ends, which is not the case in scripts and pipes.
+	if session.IsPipe() || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintf(os.Stderr, "WARN: %d conversations match, using the newest: %s\n", len(entries), entries[0].File)
+		return entries[0], nil
+	}
+
+	options := make([]string, len(entries))
+	for i, e := range entries {
+		title := e.Title
+		if title == "" {
+			title = e.FirstMessage
+		}
+		options[i] = fmt.Sprintf("%s  %s  (%s)", e.Date.Local().Format("2006-01-02 15:04"), oneLine(title, pickerTitleWidth), e.File)
+	}
+
+	prompt := &survey.Select{
+		Message:  "Choose a conversation:",
+		Options:  options,
+		PageSize: pickerPageSize,
+		Description: func(value string, index int) string {
+			return lastExchange(entries[index].Conversation())
+		},
+	}
+
+	var index int
+	filter := survey.WithFilter(func(filter string, value string, index int) bool {
+		return util.FuzzyMatch(filter, value+" "+entries[index].FirstMessage)
+	})
+	if err := survey.AskOne(prompt, &index, filter); err != nil {
+		return history.Entry{}, err
+	}
+	return entries[index], nil
+}
+
+// lastExchange previews the last user message and answer on the path to HEAD.
+func lastExchange(cv conv.Conversation) string {
+	var user, assistant string
+	for _, m := range cv.MessagesFromHead() {
+		switch m.Role {
+		case conv.ChatRoleUser:
+			user = m.Content
+			assistant = ""
+		case conv.ChatRoleAssistant:
+			assistant = m.Content
+		}
+	}
+
+	if assistant == "" {
+		return oneLine(user, pickerPreviewWidth)
+	}
+	return oneLine(user, pickerPreviewWidth/2) + " → " + oneLine(assistant, pickerPreviewWidth/2)
+}
@@ -1,48 +1,551 @@
 package lib
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/goccy/go-yaml"
+	"github.com/kznrluk/aski/command"
 	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/util"
 	"github.com/spf13/cobra"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 )
 
+// ChangeProfile chooses the current profile from a list, like ProfileUse without an argument.
 func ChangeProfile(cmd *cobra.Command, args []string) {
 	cfg, err := config.GetConfig()
 	if err != nil {
-		panic(err)
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
 	}
 
-	profileDir := config.MustGetProfileDir()
-
-	var yamlFiles []string
-
-	fileInfo, err := os.ReadDir(profileDir)
+	profiles, err := config.ListProfiles()
 	if err != nil {
-		panic(err)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(profiles) == 0 {
+		fmt.Printf("No profiles found. Create one with aski profile new.\n")
+		return
 	}
 
-	for _, file := range fileInfo {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".yaml") && file.Name() != "config.yaml" {
-			yamlFiles = append(yamlFiles, file.Name())
+	// Project profiles cannot be chosen, as the global config.yaml is read outside the project too.
+	var names []string
+	for _, p := range profiles {
+		if _, ok := globalProfilePath(p.Name); ok {
+			names = append(names, p.Name)
 		}
 	}
 
 	var selected string
 	prompt := &survey.Select{
 		Message: "Choose one option:",
-		Options: yamlFiles,
+		Options: names,
+	}
+	for _, name := range names {
+		if name == cfg.CurrentProfile {
+			prompt.Default = name
+		}
+	}
+
+	if err := survey.AskOne(prompt, &selected); err != nil {
+		fmt.Printf("Aborted.\n")
+		return
+	}
+
+	useProfile(selected)
+}
+
+func ProfileList(cmd *cobra.Command, args []string) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "\tFILE\tNAME\tMODEL\tDIRECTORY")
+	catalog := cfg.Catalog()
+	for _, p := range profiles {
+		mark := ""
+		if p.Name == cfg.CurrentProfile {
+			mark = "*"
+		}
+
+		name, model := "", ""
+		if profile, err := config.ReadProfile(p.Path, catalog); err != nil {
+			name = "(invalid, see aski profile validate)"
+		} else {
+			name, model = profile.ProfileName, profile.Model
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, p.Name, name, model, filepath.Dir(p.Path))
+	}
+	_ = w.Flush()
+}
+
+func ProfileUse(cmd *cobra.Command, args []string) {
+	useProfile(args[0])
+}
+
+// useProfile sets CurrentProfile in the global config.yaml to the profile with the name.
+// The profile must be in the global profile directory, as the global config.yaml is read outside projects too.
+func useProfile(name string) {
+	p, err := config.FindProfileFile(name)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	path, ok := globalProfilePath(p.Name)
+	if !ok {
+		fmt.Printf("error: %s is a profile of the project, use it with aski -p %s or set CurrentProfile in %s\n",
+			p.Name, p.Name, filepath.Join(filepath.Dir(filepath.Dir(p.Path)), "config.yaml"))
+		os.Exit(1)
+	}
+	if _, err := config.ReadProfile(path, config.LoadCatalog()); err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, snapshot, err := config.GetGlobalConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg.CurrentProfile = p.Name
+	if _, err := config.Save(cfg, snapshot); err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Now using %s.\n", p.Name)
+
+	if _, origins, err := config.GetEffectiveConfig(); err == nil && origins["CurrentProfile"] != filepath.Join(config.MustGetAskiDir(), "config.yaml") {
+		fmt.Printf("Note: CurrentProfile set by %s takes precedence.\n", origins["CurrentProfile"])
+	}
+}
+
+// globalProfilePath returns the path of the profile with the file name in the global profile directory,
+// and false if there is none.
+func globalProfilePath(name string) (string, bool) {
+	path := filepath.Join(config.MustGetProfileDir(), name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
+func ProfileNew(cmd *cobra.Command, args []string) {
+	project, _ := cmd.Flags().GetBool("project")
+
+	catalog := config.LoadCatalog()
+	initial := config.InitialProfile()
+	answers := struct {
+		File          string
+		ProfileName   string
+		Model         string
+		UserName      string
+		SystemContext string
+		Temperature   string
+		AutoSave      bool
+	}{}
+
+	fileDefault := ""
+	if len(args) > 0 {
+		fileDefault = args[0]
+	}
+
+	questions := []*survey.Question{
+		{
+			Name:   "File",
+			Prompt: &survey.Input{Message: "File name:", Default: fileDefault},
+			Validate: func(ans interface{}) error {
+				_, err := config.NewProfilePath(ans.(string), project)
+				return err
+			},
+		},
+		{
+			Name:     "ProfileName",
+			Prompt:   &survey.Input{Message: "Profile name, shown in the prompt:"},
+			Validate: survey.Required,
+		},
+		{
+			Name:   "Model",
+			Prompt: &survey.Input{Message: "Model:", Default: initial.Model},
+			Validate: func(ans interface{}) error {
+				p := initial
+				p.Model = catalog.Resolve(ans.(string))
+				return config.ValidateProfile(p, catalog)
+			},
+		},
+		{
+			Name:   "UserName",
+			Prompt: &survey.Input{Message: "User name:", Default: initial.UserName},
+			Validate: func(ans interface{}) error {
+				p := initial
+				p.UserName = ans.(string)
+				return config.ValidateProfile(p, catalog)
+			},
+		},
+		{
+			Name:     "SystemContext",
+			Prompt:   &survey.Multiline{Message: "System prompt:", Default: initial.SystemContext},
+			Validate: survey.Required,
+		},
+		{
+			Name:   "Temperature",
+			Prompt: &survey.Input{Message: "Temperature, empty for the model default:"},
+			Validate: func(ans interface{}) error {
+				_, err := parseTemperature(ans.(string))
+				return err
+			},
+		},
+		{
+			Name:   "AutoSave",
+			Prompt: &survey.Confirm{Message: "Save conversations automatically?", Default: initial.AutoSave},
+		},
+	}
+
+	if err := survey.Ask(questions, &answers); err != nil {
+		fmt.Printf("Aborted.\n")
+		return
+	}
+
+	profile := initial
+	profile.ProfileName = answers.ProfileName
+	// An alias is kept, the profile follows it when the catalog changes.
+	profile.Model = answers.Model
+	profile.UserName = answers.UserName
+	profile.SystemContext = answers.SystemContext
+	profile.AutoSave = answers.AutoSave
+	profile.CustomParameters.Temperature, _ = parseTemperature(answers.Temperature)
+
+	resolved := profile
+	resolved.Model = catalog.Resolve(profile.Model)
+	if err := config.ValidateProfile(resolved, catalog); err != nil {
+		fmt.Printf("error: invalid profile: %v\n", err)
+		os.Exit(1)
 	}
 
-	_ = survey.AskOne(prompt, &selected)
+	path, err := config.NewProfilePath(answers.File, project)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := yaml.Marshal(profile)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := config.CreateProfileFile(path, data); err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Created %s.\n", path)
 
-	cfg.CurrentProfile = selected
+	use := false
+	if err := survey.AskOne(&survey.Confirm{Message: "Use this profile now?"}, &use); err == nil && use {
+		useProfile(filepath.Base(path))
+	}
+}
+
+func parseTemperature(s string) (float32, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, nil
+	}
+	t, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+	if err != nil {
+		return 0, fmt.Errorf("temperature must be a number")
+	}
+	return float32(t), config.ValidateCustomParameters(config.CustomParameters{Temperature: float32(t)})
+}
+
+func ProfileEdit(cmd *cobra.Command, args []string) {
+	path := findProfileArg(args)
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The profile is edited in a copy next to it, so Extends resolves the same way, and a profile that
+	// does not validate never replaces a working one.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".aski-edit-*"+filepath.Ext(path))
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	// os.Exit skips the deferred Remove.
+	fail := func(err error) {
+		_ = os.Remove(tmpName)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	_, err = tmp.Write(original)
+	_ = tmp.Close()
+	if err != nil {
+		fail(err)
+	}
+
+	catalog := config.LoadCatalog()
+	for {
+		if err := util.EditFile(tmpName); err != nil {
+			fail(err)
+		}
+
+		edited, err := os.ReadFile(tmpName)
+		if err != nil {
+			fail(err)
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Printf("No changes.\n")
+			return
+		}
 
-	if err := config.Save(cfg); err != nil {
-		fmt.Printf("Error: %s", err.Error())
+		if errs := config.ValidateProfileFile(tmpName, catalog); len(errs) > 0 {
+			// Report the errors against the profile rather than the copy.
+			for _, err := range errs {
+				msg := strings.ReplaceAll(err.Error(), tmpName, path)
+				fmt.Printf("%s\n", strings.ReplaceAll(msg, filepath.Base(tmpName), filepath.Base(path)))
+			}
+			again := true
+			if err := survey.AskOne(&survey.Confirm{Message: "Edit again?", Default: true}, &again); err != nil || !again {
+				fmt.Printf("Discarded the changes.\n")
+				return
+			}
+			continue
+		}
+
+		perm := os.FileMode(0600)
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+		_, err = util.WriteFileChecked(path, edited, perm, util.SnapshotOf(original))
+		if errors.Is(err, util.ErrConflict) {
+			fail(fmt.Errorf("%s was changed by another process while editing, the changes were not saved", path))
+		} else if err != nil {
+			fail(err)
+		}
+		fmt.Printf("Saved %s.\n", path)
+		return
+	}
+}
+
+func ProfileShow(cmd *cobra.Command, args []string) {
+	resolved, _ := cmd.Flags().GetBool("resolved")
+
+	path := findProfileArg(args)
+
+	var data []byte
+	var err error
+	if resolved {
+		profile, loadErr := config.ReadProfile(path, config.LoadCatalog())
+		if loadErr != nil {
+			fmt.Printf("error: %v\n", loadErr)
+			os.Exit(1)
+		}
+		data, err = yaml.Marshal(profile)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("# %s\n", path)
+	_, _ = os.Stdout.Write(data)
+}
+
+// ProfileValidate checks the given profiles, or all profiles, and exits with 1 if any of them is invalid.
+func ProfileValidate(cmd *cobra.Command, args []string) {
+	paths := profileArgPaths(args)
+
+	catalog := config.LoadCatalog()
+	failed := 0
+	for _, path := range paths {
+		errs, _ := validateFile(validateTarget{path: path, kind: "profile"}, catalog)
+		if len(errs) > 0 {
+			failed++
+		}
+		printValidation(path, errs)
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d profile(s) are invalid.\n", failed, len(paths))
+		os.Exit(1)
+	}
+}
+
+func ProfileMigrate(cmd *cobra.Command, args []string) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	migrated, failed := 0, 0
+	for _, path := range profileArgPaths(args) {
+		m, err := config.PlanProfileMigration(path)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s\n     %v\n", path, err)
+			continue
+		}
+		if len(m.Applied) == 0 {
+			continue
+		}
+
+		migrated++
+		fmt.Printf("%s: version %d to %d\n", path, m.FromVersion, config.CurrentProfileVersion)
+		for _, a := range m.Applied {
+			fmt.Printf("  - %s\n", a)
+		}
+		if dryRun {
+			fmt.Printf("\n%s\n", command.FileDiff(string(m.Original), string(m.Migrated)))
+			continue
+		}
+
+		if err := m.Write(); err != nil {
+			failed++
+			fmt.Printf("error: %v\n", err)
+			continue
+		}
+		fmt.Printf("  The previous version is saved to %s\n", m.BackupPath())
+	}
+
+	switch {
+	case migrated == 0 && failed == 0:
+		fmt.Printf("All profiles are up to date.\n")
+	case dryRun:
+		fmt.Printf("%d profile(s) would be migrated, run without --dry-run to write them.\n", migrated)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// profileArgPaths returns the paths of the profiles named in args, or of all profiles without args.
+func profileArgPaths(args []string) []string {
+	var paths []string
+	if len(args) == 0 {
+		profiles, err := config.ListProfiles()
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, p := range profiles {
+			paths = append(paths, p.Path)
+		}
+		return paths
+	}
+
+	for _, arg := range args {
+		paths = append(paths, findProfileArg([]string{arg}))
+	}
+	return paths
+}
+
+func ProfileCopy(cmd *cobra.Command, args []string) {
+	project, _ := cmd.Flags().GetBool("project")
+
+	src := findProfileArg(args[:1])
+	dst, err := config.NewProfilePath(args[1], project)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := config.CreateProfileFile(dst, data); err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Copied %s to %s.\n", src, dst)
+
+	// A relative Extends may refer to a profile that is not next to the copy.
+	if _, err := config.ReadProfile(dst, config.LoadCatalog()); err != nil {
+		fmt.Printf("WARN: the copy is invalid: %v\n", err)
+	}
+}
+
+func ProfileDelete(cmd *cobra.Command, args []string) {
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	p, err := config.FindProfileFile(args[0])
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+	if current, err := config.FindProfile(cfg, ""); err == nil && current == p.Path {
+		fmt.Printf("error: %s is the current profile, choose another one with aski profile use first.\n", p.Name)
+		os.Exit(1)
+	}
+
+	extending, err := config.ExtendingProfiles(p.Path)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, e := range extending {
+		fmt.Printf("WARN: %s extends %s and will stop working.\n", e.Path, p.Name)
+	}
+
+	if !yes {
+		confirmed := false
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("Delete %s?", p.Path),
+		}
+		if err := survey.AskOne(prompt, &confirmed); err != nil || !confirmed {
+			fmt.Printf("Aborted.\n")
+			return
+		}
+	}
+
+	if err := os.Remove(p.Path); err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Deleted %s.\n", p.Path)
+}
+
+// findProfileArg returns the path of the profile named by the first argument, or of the current profile without arguments.
+// Like -p, the argument may also be a path.
+func findProfileArg(args []string) string {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	path, err := config.FindProfile(cfg, name)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
-	return
+	return path
 }
@@ -7,26 +7,250 @@ import (
 
 func main() {
 	var rootCmd = &cobra.Command{
-		Use:   "aski",
-		Short: "aski is a very small and user-friendly ChatGPT client.",
-		Long:  `aski is a very small and user-friendly ChatGPT client. It works hard to maintain context and establish communication.`,
-		Run:   lib.Aski,
+		Use:              "aski",
+		Short:            "aski is a very small and user-friendly ChatGPT client.",
+		Long:             `aski is a very small and user-friendly ChatGPT client. It works hard to maintain context and establish communication.`,
+		Args:             cobra.MaximumNArgs(1),
+		PersistentPreRun: lib.SetConfigFlags,
+		Run:              lib.Aski,
 	}
 
 	changeProfileCmd := &cobra.Command{
 		Use:   "profile",
 		Short: "Select profile.",
-		Long: "Profiles are usually located in the .aski/config.yaml file in the home directory." +
-			"By using profiles, you can easily switch between different conversation contexts on the fly.",
-		Run: lib.ChangeProfile,
+		Long: "Profiles are usually located in the .aski/profile directory in the home directory or the project. " +
+			"By using profiles, you can easily switch between different conversation contexts on the fly.\n" +
+			"Without a subcommand, choose the current profile from a list.",
+		Args: cobra.NoArgs,
+		Run:  lib.ChangeProfile,
 	}
 
+	profileListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles, marking the current one with *.",
+		Args:  cobra.NoArgs,
+		Run:   lib.ProfileList,
+	}
+
+	profileUseCmd := &cobra.Command{
+		Use:   "use <profile>",
+		Short: "Set the current profile in .aski/config.yaml.",
+		Args:  cobra.ExactArgs(1),
+		Run:   lib.ProfileUse,
+	}
+
+	profileNewCmd := &cobra.Command{
+		Use:   "new [file]",
+		Short: "Create a profile interactively.",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   lib.ProfileNew,
+	}
+	profileNewCmd.Flags().Bool("project", false, "Create the profile in the .aski/profile directory of the project.")
+
+	profileEditCmd := &cobra.Command{
+		Use:   "edit [profile]",
+		Short: "Edit a profile with $EDITOR, by default the current one.",
+		Long:  "Edit a profile with $EDITOR, by default the current one. The profile is only saved if it is valid.",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   lib.ProfileEdit,
+	}
+
+	profileValidateCmd := &cobra.Command{
+		Use:   "validate [profile...]",
+		Short: "Check profiles, by default all of them.",
+		Run:   lib.ProfileValidate,
+	}
+
+	profileMigrateCmd := &cobra.Command{
+		Use:   "migrate [profile...]",
+		Short: "Update profiles written by older versions of aski, by default all of them.",
+		Long: "Apply the migrations a profile has not had yet, keeping its comments. The previous version is saved next to it,\n" +
+			"such as default.yaml.v0.bak. Profiles are also migrated when they are loaded.",
+		Run: lib.ProfileMigrate,
+	}
+	profileMigrateCmd.Flags().BoolP("dry-run", "n", false, "Only show the changes.")
+
+	profileCopyCmd := &cobra.Command{
+		Use:   "copy <profile> <file>",
+		Short: "Copy a profile to a new file.",
+		Args:  cobra.ExactArgs(2),
+		Run:   lib.ProfileCopy,
+	}
+	profileCopyCmd.Flags().Bool("project", false, "Create the copy in the .aski/profile directory of the project.")
+
+	profileDeleteCmd := &cobra.Command{
+		Use:   "delete <profile>",
+		Short: "Delete a profile.",
+		Args:  cobra.ExactArgs(1),
+		Run:   lib.ProfileDelete,
+	}
+	profileDeleteCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation.")
+
+	profileShowCmd := &cobra.Command{
+		Use:   "show [profile]",
+		Short: "Show a profile, by default the current one.",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   lib.ProfileShow,
+	}
+	profileShowCmd.Flags().Bool("resolved", false, "Show the profile merged with the profiles it extends.")
+	changeProfileCmd.AddCommand(profileListCmd)
+	changeProfileCmd.AddCommand(profileUseCmd)
+	changeProfileCmd.AddCommand(profileNewCmd)
+	changeProfileCmd.AddCommand(profileEditCmd)
+	changeProfileCmd.AddCommand(profileShowCmd)
+	changeProfileCmd.AddCommand(profileValidateCmd)
+	changeProfileCmd.AddCommand(profileMigrateCmd)
+	changeProfileCmd.AddCommand(profileCopyCmd)
+	changeProfileCmd.AddCommand(profileDeleteCmd)
+
+	exportCmd := &cobra.Command{
+		Use:   "export <history>",
+		Short: "Export a conversation to Markdown, HTML or JSON.",
+		Long: "Export a conversation history file to Markdown, HTML or JSON. The history file is searched in pwd and .aski/history folders by prefix match.\n" +
+			"By default, only the HEAD branch is exported.",
+		Args: cobra.ExactArgs(1),
+		Run:  lib.Export,
+	}
+	exportCmd.Flags().String("format", "md", "Output format: md, html or json.")
+	exportCmd.Flags().StringP("branch", "b", "", "Export the branch ending at this sha1 or branch name instead of HEAD.")
+	exportCmd.Flags().BoolP("all", "a", false, "Export all branches.")
+	exportCmd.Flags().StringP("output", "o", "", "Write to the file instead of stdout.")
+
+	importCmd := &cobra.Command{
+		Use:   "import chatgpt|claude <file>",
+		Short: "Import conversations from ChatGPT or Claude.ai data exports.",
+		Long: "Import conversations.json from a ChatGPT or Claude.ai data export into .aski/history.\n" +
+			"Branches and timestamps are preserved, and imported conversations can be continued with -r.",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"chatgpt", "claude"},
+		Run:       lib.Import,
+	}
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration.",
+	}
+
+	configShowCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective configuration and where each value is set.",
+		Long: "Show the configuration merged from the defaults, .aski/config.yaml in the home directory and the project,\n" +
+			"ASKI_* environment variables and flags, with the API keys masked.",
+		Args: cobra.NoArgs,
+		Run:  lib.ConfigShow,
+	}
+	configShowCmd.Flags().Bool("sources", false, "Show where each API key is read from.")
+
+	configValidateCmd := &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Check config.yaml, profiles and history files for unknown keys and invalid values.",
+		Long: "Check a file, or config.yaml, the profiles and the history files of .aski in the home directory and the project.\n" +
+			"The kind of a file is guessed from its name and location unless --type is given.",
+		Args: cobra.MaximumNArgs(1),
+		Run:  lib.ConfigValidate,
+	}
+	configValidateCmd.Flags().String("type", "", "The kind of the file: config, profile or history.")
+
+	configSchemaCmd := &cobra.Command{
+		Use:       "schema config|profile|history",
+		Short:     "Print the JSON Schema of config.yaml, profiles or history files.",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"config", "profile", "history"},
+		Run:       lib.ConfigSchema,
+	}
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+
+	modelsCmd := &cobra.Command{
+		Use:   "models",
+		Short: "List the models of the catalog with their aliases, limits and prices.",
+		Long: "List the built-in models and those added by Models in config.yaml. The aliases are accepted by -m, the Model of profiles and :model.\n" +
+			"Prices are in USD per million tokens. The model of the current profile is marked with *.",
+		Args: cobra.NoArgs,
+		Run:  lib.Models,
+	}
+	modelsCmd.Flags().String("provider", "", "Only list the models of the provider: openai or anthropic.")
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Browse saved conversations.",
+		Long:  "Browse the conversation history files in .aski/history.",
+	}
+
+	historyListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved conversations, newest first.",
+		Args:  cobra.NoArgs,
+		Run:   lib.HistoryList,
+	}
+	historyListCmd.Flags().String("profile", "", "Only list conversations of this profile.")
+	historyListCmd.Flags().String("model", "", "Only list conversations whose model contains this text.")
+	historyListCmd.Flags().String("since", "", "Only list conversations on or after this date (YYYY-MM-DD).")
+	historyListCmd.Flags().String("until", "", "Only list conversations on or before this date (YYYY-MM-DD).")
+	historyListCmd.Flags().Bool("json", false, "Output JSON instead of a table.")
+
+	historySearchCmd := &cobra.Command{
+		Use:   "search <regex>",
+		Short: "Search all messages of all saved conversations.",
+		Args:  cobra.ExactArgs(1),
+		Run:   lib.HistorySearch,
+	}
+	historySearchCmd.Flags().BoolP("ignore-case", "i", false, "Ignore case when matching.")
+	historySearchCmd.Flags().Bool("json", false, "Output JSON instead of text.")
+
+	historyShowCmd := &cobra.Command{
+		Use:   "show <history>",
+		Short: "Show a saved conversation.",
+		Long:  "Show a saved conversation. The history file is searched in pwd and .aski/history folders by prefix match.",
+		Args:  cobra.ExactArgs(1),
+		Run:   lib.HistoryShow,
+	}
+	historyShowCmd.Flags().BoolP("all", "a", false, "Show all branches instead of only the HEAD branch.")
+	historyShowCmd.Flags().Bool("json", false, "Output the JSON export instead of Markdown.")
+
+	historyEncryptCmd := &cobra.Command{
+		Use:   "encrypt [history...]",
+		Short: "Encrypt saved conversations.",
+		Long: "Encrypt history files with the passphrase from ASKI_HISTORY_KEY, the HistoryKeyFile in config.yaml or a prompt.\n" +
+			"Encrypted files are decrypted transparently by -r, history and export.",
+		Run: lib.HistoryEncrypt,
+	}
+	historyEncryptCmd.Flags().BoolP("all", "a", false, "Encrypt every file in .aski/history.")
+
+	historyGCCmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete conversations exceeding the retention limits.",
+		Long: "Delete the history files exceeding HistoryRetention in config.yaml: MaxAge, MaxCount and MaxSize, with overrides per profile.\n" +
+			"Conversations marked with :keep or tagged with :tag are never deleted. Set Auto to run this when aski starts.",
+		Args: cobra.NoArgs,
+		Run:  lib.HistoryGC,
+	}
+	historyGCCmd.Flags().BoolP("dry-run", "n", false, "Only show what would be deleted.")
+
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historySearchCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyEncryptCmd)
+	historyCmd.AddCommand(historyGCCmd)
+
 	rootCmd.AddCommand(changeProfileCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(modelsCmd)
 	rootCmd.PersistentFlags().StringSliceP("file", "f", []string{}, "Input file(s) to start dialog from. Can be specified multiple times.")
 	rootCmd.PersistentFlags().StringP("profile", "p", "", "Select the profile to use for this conversation, as defined in the .aski/config.yaml file.")
 	rootCmd.PersistentFlags().StringP("content", "c", "", "Input text to start dialog from command line")
-	rootCmd.PersistentFlags().StringP("model", "m", "", "Override the model to use for this conversation. This will override the model specified in the profile.")
-	rootCmd.PersistentFlags().StringP("restore", "r", "", "Restore conversations from history yaml files. Search pwd and .aski/history folders by default. Prefix match.")
+	rootCmd.PersistentFlags().StringP("model", "m", "", "Override the model to use for this conversation. This will override the model specified in the profile. Accepts the aliases of aski models.")
+	rootCmd.PersistentFlags().Float32("temperature", 0, "Override the temperature of the profile for this conversation.")
+	rootCmd.PersistentFlags().Int("max-tokens", 0, "Override the maximum number of tokens of an answer for this conversation.")
+	rootCmd.PersistentFlags().String("system", "", "Override the system prompt of the profile for this conversation.")
+	rootCmd.PersistentFlags().StringArray("var", []string{}, "Set a variable for the templates of the profile as name=value. Can be specified multiple times.")
+	rootCmd.PersistentFlags().StringP("restore", "r", "", "Restore conversations from history yaml files. Search pwd and .aski/history folders by default. Prefix match, falling back to conversation titles. Without a value, or when several match, choose from a list.")
+	rootCmd.PersistentFlags().Lookup("restore").NoOptDefVal = lib.RestorePick
 	rootCmd.PersistentFlags().BoolP("rest", "", false, "When you specify this flag, you will communicate with the REST API instead of streaming. This can be useful if the communication is unstable or if you are not receiving responses properly.")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Debug logging")
 
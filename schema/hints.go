@@ -0,0 +1,71 @@
+package schema
+
+// The hints add descriptions and the constraints checked by config.ValidateProfile and friends to the generated
+// schemas, by the dotted path of the value. Keep them in sync with the validation.
+
+var profileHints = map[string]Schema{
+	"ProfileVersion":                     {"description": "The version of the file layout. Older profiles are migrated when they are loaded, see aski profile migrate.", "minimum": 0},
+	"Extends":                            {"description": "The profile this one is based on. Values of this profile replace those of the base, lists under keys ending with + are appended."},
+	"ProfileName":                        {"description": "The name shown in the prompt.", "minLength": 1},
+	"Model":                              {"description": "The model, such as gpt-4-turbo-preview or claude-3-opus-20240229, or an alias of the catalog such as opus. See aski models."},
+	"UserName":                           {"description": "The name sent as the sender of the messages.", "pattern": "^[a-zA-Z0-9/\\\\]{1,16}$"},
+	"AutoSave":                           {"description": "Save the conversation after every answer."},
+	"Summarize":                          {"description": "Summarize older messages when the conversation gets close to the context limit of the model."},
+	"AutoTitle":                          {"description": "Generate a title after the first answer."},
+	"TitleModel":                         {"description": "The model that generates titles, by default a cheap model of the same provider."},
+	"ResponseFormat":                     {"description": "json_object makes GPT models answer with JSON.", "enum": []string{"text", "json_object"}},
+	"SystemContext":                      {"description": "The system prompt. Rendered as a Go template when a conversation starts.", "minLength": 1},
+	"Messages":                           {"description": "Messages sent at the beginning of the conversation."},
+	"Messages[].Role":                    {"minLength": 1},
+	"Messages[].Content":                 {"description": "Rendered as a Go template when a conversation starts.", "minLength": 1},
+	"CustomParameters":                   {"description": "Parameters overwriting the defaults of the API."},
+	"CustomParameters.temperature":       {"minimum": 0, "maximum": 2},
+	"CustomParameters.top_p":             {"minimum": 0, "maximum": 1},
+	"CustomParameters.stop":              {"maxItems": 4},
+	"CustomParameters.presence_penalty":  {"minimum": -2, "maximum": 2},
+	"CustomParameters.frequency_penalty": {"minimum": -2, "maximum": 2},
+	"CustomParameters.logit_bias.*":      {"minimum": -100, "maximum": 100},
+	"DiceRoll":                           {"description": "Roll dice with every message, such as 3d6.", "pattern": "^[0-9]+[dD][0-9]+$"},
+}
+
+var configHints = map[string]Schema{
+	"OpenAIAPIKey":              {"description": "The OpenAI API key, or file: followed by the path of a file containing it."},
+	"AnthropicAPIKey":           {"description": "The Anthropic API key, or file: followed by the path of a file containing it."},
+	"APIKeyCommand":             {"description": "Commands printing the API keys, such as pass show openai."},
+	"CurrentProfile":            {"description": "The file name of the profile used by default."},
+	"EncryptHistory":            {"description": "Encrypt new history files."},
+	"HistoryKeyFile":            {"description": "A file containing the passphrase of the history files."},
+	"HistoryRetention":          {"description": "Limits of the conversations kept, see aski history gc."},
+	"HistoryRetention.MaxAge":   {"description": "Conversations not continued for this long are deleted, such as 90d, 12w or 720h.", "pattern": "^([0-9]+[dw]|([0-9.]+(ns|us|µs|ms|s|m|h))+)$"},
+	"HistoryRetention.MaxCount": {"description": "The number of newest conversations to keep.", "minimum": 0},
+	"HistoryRetention.MaxSize":  {"description": "The total size of the conversations to keep, such as 500KB or 100MB.", "pattern": "^[0-9.]+ *([kKmMgG][bB]?|[bB])?$"},
+	"HistoryRetention.Auto":     {"description": "Collect garbage when aski starts."},
+	"HistoryRetention.Profiles": {"description": "Limits for the conversations of a profile, by ProfileName."},
+	"Overrides":                 {"description": "Values replacing those of the profile for new conversations."},
+	"Overrides.Temperature":     {"minimum": 0, "maximum": 2},
+	"Overrides.MaxTokens":       {"minimum": 0},
+	"TemplateShell":             {"description": "Enable {{shell}} in the templates of profiles. Ignored in project configs."},
+	"TrustedProjects":           {"description": "Project directories whose profiles may use shell, file and env like global profiles. Ignored in project configs."},
+	"Overrides.Model":           {"description": "The model, or an alias of the catalog such as opus."},
+	"Models":                    {"description": "Models added to the built-in catalog, or replacing those with the same ID. See aski models."},
+	"Models[]":                  {"required": []string{"ID"}},
+	"Models[].ID":               {"description": "The name of the model sent to the API."},
+	"Models[].Aliases":          {"description": "Short names accepted by -m, the Model of profiles and :model."},
+	"Models[].Provider":         {"description": "The provider the model is sent to, required unless the ID starts with gpt or claude.", "enum": []string{"openai", "anthropic"}},
+	"Models[].ContextWindow":    {"description": "The number of tokens the model accepts.", "minimum": 0},
+	"Models[].MaxOutputTokens":  {"description": "The number of tokens the model answers at most.", "minimum": 0},
+	"Models[].Modalities":       {"description": "The kinds of input the model understands, such as text and image."},
+	"Models[].InputPrice":       {"description": "The price in USD per million input tokens.", "minimum": 0},
+	"Models[].OutputPrice":      {"description": "The price in USD per million output tokens.", "minimum": 0},
+}
+
+var historyHints = map[string]Schema{
+	"version":               {"description": "The version of the file format. Older files are migrated when they are read."},
+	"profile":               {"description": "The profile the conversation was started with."},
+	"system":                {"description": "The system prompt at the root of the conversation."},
+	"messages":              {"description": "The messages of the conversation tree, linked by parentsha1."},
+	"messages[].parentsha1": {"description": "The sha1 of the parent message, or ROOT."},
+	"messages[].role":       {"enum": []string{"user", "assistant", "system", "summary"}},
+	"messages[].head":       {"description": "Whether the message is the current HEAD. Exactly one message is."},
+	"branches":              {"description": "Branch names and the sha1 of their tips."},
+}
@@ -0,0 +1,153 @@
+// Package schema generates the JSON Schemas of config.yaml, profiles and history files from their Go types,
+// for editors that complete and check YAML files, such as VS Code with the YAML extension.
+// The generated schemas are committed next to this file, run `go test ./schema -update` after changing the types.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kznrluk/aski/config"
+	"github.com/kznrluk/aski/conv"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema document or a part of it.
+type Schema map[string]interface{}
+
+// BaseURL is where the generated schemas are published.
+const BaseURL = "https://raw.githubusercontent.com/kznrluk/aski/main/schema/"
+
+// Kinds are the files that have a schema.
+var Kinds = []string{"config", "profile", "history"}
+
+// For returns the schema of the kind of file.
+func For(kind string) (Schema, error) {
+	switch kind {
+	case "config":
+		return generate(kind, "aski config.yaml", reflect.TypeOf(config.Config{}), configHints, true), nil
+	case "profile":
+		s := generate(kind, "aski profile", reflect.TypeOf(config.Profile{}), profileHints, true)
+		// The required values may come from the profile it extends.
+		s["if"] = Schema{"not": Schema{"required": []string{"Extends"}}}
+		s["then"] = Schema{"required": []string{"ProfileName", "Model", "UserName", "SystemContext"}}
+		return s, nil
+	case "history":
+		// The conversation type is not exported, NewConversation returns a pointer to it.
+		t := reflect.TypeOf(conv.NewConversation(config.Profile{})).Elem()
+		s := generate(kind, "aski history file", t, historyHints, false)
+		s["required"] = []string{"version", "messages"}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown schema %q, expected one of %s", kind, strings.Join(Kinds, ", "))
+}
+
+// JSON returns the schema as indented JSON.
+func (s Schema) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// generator converts Go types to schemas. Types that contain themselves, like config.Retention, are put in $defs.
+type generator struct {
+	hints      map[string]Schema
+	appendKeys bool
+	visiting   map[reflect.Type]bool
+	recursive  map[reflect.Type]bool
+	defs       Schema
+}
+
+func generate(kind string, title string, t reflect.Type, hints map[string]Schema, appendKeys bool) Schema {
+	g := generator{
+		hints:      hints,
+		appendKeys: appendKeys,
+		visiting:   map[reflect.Type]bool{},
+		recursive:  map[reflect.Type]bool{},
+		defs:       Schema{},
+	}
+
+	s := g.schema(t, "")
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["$id"] = BaseURL + kind + ".schema.json"
+	s["title"] = title
+	if len(g.defs) > 0 {
+		s["$defs"] = g.defs
+	}
+	return s
+}
+
+// schema returns the schema of t at the dotted path, such as "CustomParameters.temperature".
+// The path of the items of a list ends with "[]", and of the values of a map with ".*".
+func (g generator) schema(t reflect.Type, path string) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var s Schema
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		s = Schema{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		s = Schema{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		s = Schema{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = Schema{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = Schema{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = Schema{"type": "array", "items": g.schema(t.Elem(), path+"[]")}
+	case t.Kind() == reflect.Map:
+		s = Schema{"type": "object", "additionalProperties": g.schema(t.Elem(), path+".*")}
+	case t.Kind() == reflect.Struct:
+		if g.visiting[t] {
+			g.recursive[t] = true
+			return Schema{"$ref": "#/$defs/" + t.Name()}
+		}
+		g.visiting[t] = true
+		s = g.object(t, path)
+		delete(g.visiting, t)
+
+		if g.recursive[t] {
+			g.defs[t.Name()] = s
+			s = Schema{"$ref": "#/$defs/" + t.Name()}
+		}
+	default:
+		s = Schema{}
+	}
+
+	for k, v := range g.hints[strings.TrimPrefix(path, ".")] {
+		s[k] = v
+	}
+	return s
+}
+
+func (g generator) object(t reflect.Type, path string) Schema {
+	properties := Schema{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.ToLower(f.Name)
+		if tag := strings.Split(f.Tag.Get("yaml"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		fieldPath := strings.TrimPrefix(path+"."+name, ".")
+		properties[name] = g.schema(f.Type, fieldPath)
+
+		// Lists can be appended to those of other layers or of the base profile, see config.MergeLayers.
+		if g.appendKeys && f.Type.Kind() == reflect.Slice {
+			properties[name+"+"] = g.schema(f.Type, fieldPath)
+		}
+	}
+	return Schema{"type": "object", "properties": properties, "additionalProperties": false}
+}
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the published schemas")
+
+// TestSchemas compares the generated schemas with the published ones. Run `go test ./schema -update` after
+// changing the types or the hints.
+func TestSchemas(t *testing.T) {
+	for _, kind := range Kinds {
+		t.Run(kind, func(t *testing.T) {
+			s, err := For(kind)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := s.JSON()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			path := kind + ".schema.json"
+			if *update {
+				if err := os.WriteFile(path, got, 0644); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("cannot read %s, run with -update to create it: %v", path, err)
+			}
+			if string(got) != string(expected) {
+				t.Errorf("%s is outdated, run go test ./schema -update", path)
+			}
+		})
+	}
+}
+
+func TestForUnknownKind(t *testing.T) {
+	if _, err := For("unknown"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -2,8 +2,9 @@ package session
 
 type (
 	globalFlags struct {
-		Verbose bool
-		IsPipe  bool
+		Verbose  bool
+		IsPipe   bool
+		RestMode bool
 	}
 )
 
@@ -24,3 +25,11 @@ func SetIsPipe(pipe bool) {
 func IsPipe() bool {
 	return flags.IsPipe
 }
+
+func SetRestMode(rest bool) {
+	flags.RestMode = rest
+}
+
+func RestMode() bool {
+	return flags.RestMode
+}
@@ -0,0 +1,146 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DiffEqual DiffKind = iota
+	DiffDelete
+	DiffInsert
+)
+
+type (
+	DiffKind int
+
+	DiffOp struct {
+		Kind DiffKind
+		Text string
+	}
+)
+
+var wordPattern = regexp.MustCompile(`\s+|[^\s]+`)
+
+// SplitWords splits text into words and the whitespace between them, so joining the result gives the text back.
+func SplitWords(text string) []string {
+	return wordPattern.FindAllString(text, -1)
+}
+
+// SplitLines splits text into lines keeping the line breaks, so joining the result gives the text back.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Diff returns the operations that turn a into b, based on the longest common subsequence of the tokens.
+// Adjacent operations of the same kind are merged. It uses Hirschberg's algorithm, so memory grows with the length
+// of the texts rather than with their product, as long answers and attached files are compared word by word.
+func Diff(a, b []string) []DiffOp {
+	var d differ
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		d.add(DiffEqual, a[prefix])
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	d.diff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, token := range a[len(a)-suffix:] {
+		d.add(DiffEqual, token)
+	}
+	return d.ops
+}
+
+type differ struct {
+	ops []DiffOp
+}
+
+func (d *differ) add(kind DiffKind, text string) {
+	if len(d.ops) > 0 && d.ops[len(d.ops)-1].Kind == kind {
+		d.ops[len(d.ops)-1].Text += text
+		return
+	}
+	d.ops = append(d.ops, DiffOp{Kind: kind, Text: text})
+}
+
+func (d *differ) diff(a, b []string) {
+	switch {
+	case len(a) == 0:
+		for _, token := range b {
+			d.add(DiffInsert, token)
+		}
+	case len(b) == 0:
+		for _, token := range a {
+			d.add(DiffDelete, token)
+		}
+	case len(a) == 1:
+		for j, token := range b {
+			if token == a[0] {
+				d.diff(nil, b[:j])
+				d.add(DiffEqual, token)
+				d.diff(nil, b[j+1:])
+				return
+			}
+		}
+		d.add(DiffDelete, a[0])
+		d.diff(nil, b)
+	default:
+		// Split b where the common subsequences of both halves of a add up to the longest.
+		mid := len(a) / 2
+		head := lcsHead(a[:mid], b)
+		tail := lcsTail(a[mid:], b)
+		split, best := 0, -1
+		for j := 0; j <= len(b); j++ {
+			if n := head[j] + tail[j]; n > best {
+				split, best = j, n
+			}
+		}
+		d.diff(a[:mid], b[:split])
+		d.diff(a[mid:], b[split:])
+	}
+}
+
+// lcsHead returns the lengths of the longest common subsequences of a and b[:j] for every j.
+func lcsHead(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsTail returns the lengths of the longest common subsequences of a and b[j:] for every j.
+func lcsTail(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				cur[j] = prev[j+1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        string
+		b        string
+		split    func(string) []string
+		expected []DiffOp
+	}{
+		{
+			name:  "Word change",
+			a:     "the quick brown fox",
+			b:     "the slow brown fox",
+			split: SplitWords,
+			expected: []DiffOp{
+				{Kind: DiffEqual, Text: "the "},
+				{Kind: DiffDelete, Text: "quick"},
+				{Kind: DiffInsert, Text: "slow"},
+				{Kind: DiffEqual, Text: " brown fox"},
+			},
+		},
+		{
+			name:  "Line added",
+			a:     "a\nc\n",
+			b:     "a\nb\nc\n",
+			split: SplitLines,
+			expected: []DiffOp{
+				{Kind: DiffEqual, Text: "a\n"},
+				{Kind: DiffInsert, Text: "b\n"},
+				{Kind: DiffEqual, Text: "c\n"},
+			},
+		},
+		{
+			name:     "Empty to text",
+			a:        "",
+			b:        "new",
+			split:    SplitLines,
+			expected: []DiffOp{{Kind: DiffInsert, Text: "new"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Diff(tc.split(tc.a), tc.split(tc.b))
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, result)
+			}
+
+			var a, b strings.Builder
+			for _, op := range result {
+				if op.Kind != DiffInsert {
+					a.WriteString(op.Text)
+				}
+				if op.Kind != DiffDelete {
+					b.WriteString(op.Text)
+				}
+			}
+			if a.String() != tc.a || b.String() != tc.b {
+				t.Errorf("Expected the operations to rebuild both texts, but got %q and %q", a.String(), b.String())
+			}
+		})
+	}
+}
+
+func TestDiffLongestCommonSubsequence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		tokens := make([]string, rng.Intn(30))
+		for i := range tokens {
+			tokens[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return tokens
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := random(), random()
+
+		// The length of the longest common subsequence, computed with the full table.
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := range a {
+			for j := range b {
+				if a[i] == b[j] {
+					lcs[i+1][j+1] = lcs[i][j] + 1
+				} else {
+					lcs[i+1][j+1] = max(lcs[i][j+1], lcs[i+1][j])
+				}
+			}
+		}
+
+		var equal int
+		var rebuiltA, rebuiltB strings.Builder
+		for _, op := range Diff(a, b) {
+			if op.Kind == DiffEqual {
+				equal += len(op.Text)
+			}
+			if op.Kind != DiffInsert {
+				rebuiltA.WriteString(op.Text)
+			}
+			if op.Kind != DiffDelete {
+				rebuiltB.WriteString(op.Text)
+			}
+		}
+		if equal != lcs[len(a)][len(b)] {
+			t.Errorf("%v %v: expected %d equal tokens, got %d", a, b, lcs[len(a)][len(b)], equal)
+		}
+		if rebuiltA.String() != strings.Join(a, "") || rebuiltB.String() != strings.Join(b, "") {
+			t.Errorf("%v %v: the operations do not rebuild both texts", a, b)
+		}
+	}
+}
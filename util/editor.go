@@ -0,0 +1,38 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// EditFile opens the file with the editor in the EDITOR environment variable and waits until it is closed.
+// The default is notepad on Windows and vim elsewhere.
+func EditFile(path string) error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad.exe"
+		} else {
+			editor = "vim"
+		}
+	}
+
+	cmd := exec.Command(editor, path)
+
+	// for vscode :)
+	if strings.Contains(editor, "code") {
+		cmd = exec.Command(editor, "--wait", path)
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to open editor: %v", err)
+	}
+	return nil
+}
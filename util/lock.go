@@ -0,0 +1,110 @@
+package util
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockTimeout is how long LockFile waits for another process to release a lock.
+const lockTimeout = 5 * time.Second
+
+var (
+	// ErrLocked is returned when another process holds the lock for longer than lockTimeout.
+	ErrLocked = errors.New("the file is being written by another aski process")
+	// ErrConflict is returned by WriteFileChecked when another process changed the file since it was read.
+	ErrConflict = errors.New("the file was changed by another aski process")
+)
+
+// lockFileName is the file holding the locks of the files in its directory.
+const lockFileName = ".aski.lock"
+
+// FileLock is an advisory lock on a file, held on a single lock file in its directory.
+// The lock file is shared by the files of the directory and left in place, as removing it would race with processes
+// waiting for it. Renaming or deleting the files leaves no lock files behind.
+type FileLock struct {
+	f *os.File
+}
+
+// Snapshot identifies the contents of a file at the time it was read.
+type Snapshot struct {
+	Exists bool
+	Hash   [sha256.Size]byte
+}
+
+// LockFile takes the lock of path, waiting up to lockTimeout for other processes.
+// Other files in the same directory share the lock, so a process must not take two of them at once.
+func LockFile(path string) (*FileLock, error) {
+	f, err := os.OpenFile(filepath.Join(filepath.Dir(path), lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if ok {
+			return &FileLock{f: f}, nil
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("%s: %w", path, ErrLocked)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (l *FileLock) Unlock() error {
+	if err := unlock(l.f); err != nil {
+		_ = l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// SnapshotOf returns the snapshot of data read from a file.
+func SnapshotOf(data []byte) Snapshot {
+	return Snapshot{Exists: true, Hash: sha256.Sum256(data)}
+}
+
+// TakeSnapshot reads the file at path. A missing file has the zero Snapshot.
+func TakeSnapshot(path string) (Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Snapshot{}, nil
+	} else if err != nil {
+		return Snapshot{}, err
+	}
+	return SnapshotOf(data), nil
+}
+
+// WriteFileChecked writes data to path atomically while holding its lock.
+// If the file no longer matches expected, it is left as is and ErrConflict is returned,
+// so changes by other processes are not silently overwritten. It returns the snapshot of data.
+func WriteFileChecked(path string, data []byte, perm os.FileMode, expected Snapshot) (Snapshot, error) {
+	lock, err := LockFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer lock.Unlock()
+
+	current, err := TakeSnapshot(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if current != expected {
+		return Snapshot{}, fmt.Errorf("%s: %w", path, ErrConflict)
+	}
+
+	if err := WriteFileAtomic(path, data, perm); err != nil {
+		return Snapshot{}, err
+	}
+	return SnapshotOf(data), nil
+}
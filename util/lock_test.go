@@ -0,0 +1,78 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileChecked(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.yaml")
+
+	// A new file must not exist yet.
+	first, err := WriteFileChecked(path, []byte("first"), 0600, Snapshot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteFileChecked(path, []byte("other"), 0600, Snapshot{}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict for an existing file, got %v", err)
+	}
+
+	second, err := WriteFileChecked(path, []byte("second"), 0600, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process writes the file.
+	if err := os.WriteFile(path, []byte("changed elsewhere"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteFileChecked(path, []byte("third"), 0600, second); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "changed elsewhere" {
+		t.Errorf("the other change was overwritten: %q", got)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	lock, err := LockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lock can be taken again once released.
+	lock, err = LockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The files of a directory share one lock file, so renamed and deleted files leave none behind.
+	for _, name := range []string{"a.yaml", "b.yaml"} {
+		if _, err := WriteFileChecked(filepath.Join(filepath.Dir(path), name), []byte(name), 0600, Snapshot{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("expected a.yaml, b.yaml and one lock file, got %v", entries)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 func IsBinary(contents []byte) bool {
@@ -41,3 +42,33 @@ func RollDice(diceRoll string) (int, error) {
 
 	return sum, nil
 }
+
+// EstimateTokens roughly estimates the number of tokens in text, assuming four characters per token for ASCII
+// and a token for every other character, as tokenizers split CJK text and other scripts into far shorter tokens.
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// FuzzyMatch reports whether the characters of pattern appear in text in order, ignoring case.
+func FuzzyMatch(pattern string, text string) bool {
+	target := []rune(strings.ToLower(text))
+	i := 0
+	for _, r := range strings.ToLower(pattern) {
+		for i < len(target) && target[i] != r {
+			i++
+		}
+		if i == len(target) {
+			return false
+		}
+		i++
+	}
+	return true
+}
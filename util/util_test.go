@@ -0,0 +1,42 @@
+package util
+
+import "testing"
+
+func TestFuzzyMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pattern  string
+		text     string
+		expected bool
+	}{
+		{name: "Empty pattern", pattern: "", text: "anything", expected: true},
+		{name: "Subsequence", pattern: "shpp", text: "Shell Pipes", expected: true},
+		{name: "Ignores case", pattern: "PIPE", text: "shell pipes", expected: true},
+		{name: "Wrong order", pattern: "sp", text: "pipes", expected: false},
+		{name: "Missing character", pattern: "pipez", text: "shell pipes", expected: false},
+		{name: "Non-ASCII", pattern: "パプ", text: "パイプとは", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FuzzyMatch(tc.pattern, tc.text); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	testCases := map[string]int{
+		"":                0,
+		"abcd":            1,
+		"hello world":     3,
+		"こんにちは":           5,
+		"aski は会話を保存します。": 12,
+	}
+	for text, expected := range testCases {
+		if got := EstimateTokens(text); got != expected {
+			t.Errorf("EstimateTokens(%q): expected %d, got %d", text, expected, got)
+		}
+	}
+}